@@ -0,0 +1,79 @@
+package gocache
+
+import (
+	"GoCache/gocache/bloom"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// KeySource 返回所有存在的key，用于构建布隆过滤器，例如从数据库中查询全部主键。
+type KeySource func(ctx context.Context) ([]string, error)
+
+// 布隆过滤器的配置。
+type bloomConfig struct {
+	expectedItems int     // 预计的key的数量。
+	fpRate        float64 // 期望的误判率。
+	source        KeySource
+
+	// 以下字段在持有mu时访问。重建过滤器期间加入的key记录在pending中，替换之前补充到新的过滤器，
+	// 否则在KeySource的快照之后写入的key只会进入被丢弃的旧过滤器，之后被新过滤器误拒。
+	mu         sync.Mutex
+	rebuilding int // 正在进行的LoadBloomFilter的数量。
+	pending    []string
+}
+
+// LoadBloomFilter 从KeySource加载所有的key，构建新的布隆过滤器并替换旧的过滤器。
+// 可以定期调用以清理已经被删除的key。加载完成之前，Get不会拒绝任何key。
+func (g *Group) LoadBloomFilter(ctx context.Context) error {
+	if g.bloomConfig == nil {
+		return fmt.Errorf("bloom filter is not enabled for group %s", g.name)
+	}
+	cfg := g.bloomConfig
+	cfg.mu.Lock()
+	cfg.rebuilding++
+	cfg.mu.Unlock()
+	defer func() {
+		cfg.mu.Lock()
+		if cfg.rebuilding--; cfg.rebuilding == 0 {
+			cfg.pending = nil
+		}
+		cfg.mu.Unlock()
+	}()
+
+	keys, err := cfg.source(ctx)
+	if err != nil {
+		return err
+	}
+	n := g.bloomConfig.expectedItems
+	if len(keys) > n {
+		n = len(keys)
+	}
+	f := bloom.New(n, g.bloomConfig.fpRate)
+	for _, key := range keys {
+		f.Add(key)
+	}
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	for _, key := range cfg.pending {
+		f.Add(key)
+	}
+	g.bloom.Store(f)
+	return nil
+}
+
+// 将key加入布隆过滤器，例如Set写入了新的key。
+func (g *Group) addToBloomFilter(key string) {
+	if g.bloomConfig == nil {
+		return
+	}
+	cfg := g.bloomConfig
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	if cfg.rebuilding > 0 {
+		cfg.pending = append(cfg.pending, key)
+	}
+	if f := g.bloom.Load(); f != nil {
+		f.Add(key)
+	}
+}
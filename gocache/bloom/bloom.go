@@ -0,0 +1,74 @@
+package bloom
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// Filter 布隆过滤器，用来判断一个key是否一定不存在。
+// Test返回false时key一定没有被Add过，返回true时key可能存在（存在误判）。可以被并发地读写。
+type Filter struct {
+	bits []uint64 // 位数组，使用原子操作读写。
+	m    uint64   // 位数组的长度。
+	k    uint64   // 哈希函数的个数。
+}
+
+// New 根据预计的元素个数n和期望的误判率fpRate，计算位数组长度和哈希函数个数，实例化Filter。
+// m = -n*ln(p)/(ln2)^2，k = m/n*ln2。
+func New(n int, fpRate float64) *Filter {
+	if n < 1 {
+		n = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.01
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	// 位数组的长度向上取整为64的倍数。
+	words := (m + 63) / 64
+	return &Filter{
+		bits: make([]uint64, words),
+		m:    words * 64,
+		k:    k,
+	}
+}
+
+// Add 将key加入过滤器。
+func (f *Filter) Add(key string) {
+	h1, h2 := hash(key)
+	for i := uint64(0); i < f.k; i++ {
+		idx := (h1 + i*h2) % f.m
+		word, mask := &f.bits[idx/64], uint64(1)<<(idx%64)
+		for {
+			old := atomic.LoadUint64(word)
+			if old&mask != 0 || atomic.CompareAndSwapUint64(word, old, old|mask) {
+				break
+			}
+		}
+	}
+}
+
+// Test 判断key是否可能存在。返回false代表key一定不存在。
+func (f *Filter) Test(key string) bool {
+	h1, h2 := hash(key)
+	for i := uint64(0); i < f.k; i++ {
+		idx := (h1 + i*h2) % f.m
+		if atomic.LoadUint64(&f.bits[idx/64])&(uint64(1)<<(idx%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// 使用64位FNV-1a哈希值的高低32位作为两个独立的哈希函数，通过双重哈希 h1 + i*h2 模拟k个哈希函数。
+func hash(key string) (uint64, uint64) {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h >> 32, h<<32>>32 | 1
+}
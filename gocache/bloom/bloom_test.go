@@ -0,0 +1,32 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	const n = 10000
+	f := New(n, 0.01)
+	for i := 0; i < n; i++ {
+		f.Add("key" + strconv.Itoa(i))
+	}
+
+	// 加入过的key一定返回true。
+	for i := 0; i < n; i++ {
+		if !f.Test("key" + strconv.Itoa(i)) {
+			t.Fatalf("false negative for key%d", i)
+		}
+	}
+
+	// 没有加入过的key，误判率应当接近期望值。
+	falsePositives := 0
+	for i := 0; i < n; i++ {
+		if f.Test("other" + strconv.Itoa(i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / n; rate > 0.03 {
+		t.Fatalf("false positive rate %.4f is too high", rate)
+	}
+}
@@ -0,0 +1,152 @@
+package gocache
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// 默认连续失败多少次后断开熔断器。
+	defaultBreakerThreshold = 5
+	// 默认熔断器断开多久之后允许一次试探请求。
+	defaultBreakerCooldown = 10 * time.Second
+)
+
+// ErrCircuitOpen 远程节点的熔断器处于断开状态，请求没有发出。
+var ErrCircuitOpen = errors.New("gocache: peer circuit breaker is open")
+
+// BreakerState 熔断器的状态。
+type BreakerState int
+
+const (
+	// BreakerClosed 正常状态，请求正常发出，连续失败达到阈值时断开。
+	BreakerClosed BreakerState = iota
+	// BreakerOpen 断开状态，请求直接失败，节点暂时不参与路由。冷却时间过后进入半开状态。
+	BreakerOpen
+	// BreakerHalfOpen 半开状态，只允许一个试探请求，成功时闭合，失败时重新断开。
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// circuitBreaker 单个远程节点的熔断器。连续失败threshold次后断开，cooldown之后半开，允许一个试探请求。
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time // 返回当前时间，测试时可以替换。
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int       // 连续失败的次数。
+	openedAt time.Time // 最近一次断开的时间。
+	probing  bool      // 半开状态下是否已经有试探请求在进行。
+}
+
+// 实例化熔断器，threshold <= 0代表不使用熔断器，返回nil。nil熔断器总是允许请求。
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// 判断是否允许发出请求。冷却时间过后的第一个请求作为试探请求，熔断器进入半开状态。
+func (b *circuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// 判断节点是否可以参与路由，与allow不同，不会改变熔断器的状态。
+// 断开状态的冷却时间已过，或者半开状态还没有试探请求时，节点重新参与路由，由下一个请求试探。
+func (b *circuitBreaker) available() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		return b.now().Sub(b.openedAt) >= b.cooldown
+	case BreakerHalfOpen:
+		return !b.probing
+	}
+	return true
+}
+
+// 记录一次请求的结果。
+func (b *circuitBreaker) record(success bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if success {
+		b.state = BreakerClosed
+		b.failures = 0
+		b.probing = false
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+		b.probing = false
+	}
+}
+
+// 请求被调用方主动取消，不记录结果。半开状态下释放试探请求的名额，由下一个请求重新试探，
+// 否则熔断器会一直停留在半开状态，节点不再参与路由。
+func (b *circuitBreaker) cancel() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+	}
+}
+
+// 返回熔断器当前的状态。
+func (b *circuitBreaker) current() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,75 @@
+package gocache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newCircuitBreaker(2, time.Second)
+	b.now = func() time.Time { return now }
+
+	b.record(false)
+	if b.current() != BreakerClosed || !b.allow() {
+		t.Fatal("breaker should stay closed below threshold")
+	}
+	b.record(true)
+	b.record(false)
+	if b.current() != BreakerClosed {
+		t.Fatal("success should reset consecutive failures")
+	}
+	b.record(false)
+	if b.current() != BreakerOpen || b.allow() || b.available() {
+		t.Fatalf("breaker should open after 2 failures, got %s", b.current())
+	}
+
+	// 冷却时间过后只允许一个试探请求，试探失败时重新断开。
+	now = now.Add(time.Second)
+	if !b.available() || !b.allow() || b.current() != BreakerHalfOpen {
+		t.Fatalf("breaker should allow a probe after cooldown, got %s", b.current())
+	}
+	if b.allow() || b.available() {
+		t.Fatal("only one probe should be allowed while half-open")
+	}
+	b.record(false)
+	if b.current() != BreakerOpen || b.allow() {
+		t.Fatalf("failed probe should reopen breaker, got %s", b.current())
+	}
+
+	now = now.Add(time.Second)
+	if !b.allow() {
+		t.Fatal("breaker should allow a probe after cooldown")
+	}
+	b.record(true)
+	if b.current() != BreakerClosed || !b.allow() || !b.allow() {
+		t.Fatalf("successful probe should close breaker, got %s", b.current())
+	}
+
+	disabled := newCircuitBreaker(0, 0)
+	disabled.record(false)
+	if disabled != nil || !disabled.allow() || !disabled.available() || disabled.current() != BreakerClosed {
+		t.Fatal("threshold <= 0 should disable the breaker")
+	}
+}
+
+func TestCircuitBreakerCancel(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newCircuitBreaker(1, time.Second)
+	b.now = func() time.Time { return now }
+	b.record(false)
+
+	// 试探请求被调用方取消后，下一个请求可以重新试探。
+	now = now.Add(time.Second)
+	if !b.allow() {
+		t.Fatal("breaker should allow a probe after cooldown")
+	}
+	b.cancel()
+	if b.current() != BreakerHalfOpen || !b.available() || !b.allow() {
+		t.Fatalf("cancelled probe should release the probe slot, got %s", b.current())
+	}
+	b.record(true)
+	if b.current() != BreakerClosed {
+		t.Fatalf("successful probe should close breaker, got %s", b.current())
+	}
+}
@@ -1,9 +1,13 @@
 package gocache
 
+import "time"
+
 // ByteView 只读数据结构，用来表示缓存值（value）。
 type ByteView struct {
 	// b会存储真正的缓存值。选择byte类型是为了能够支持任意的数据类型的存储，比如字符串、图片等。
 	b []byte
+	// 开启WithStaleWhileRevalidate时，缓存值在stale时刻变旧（软过期），在expire时刻硬过期。零值代表不区分新旧。
+	stale, expire time.Time
 }
 
 // Len 返回缓存值的长度。
@@ -2,38 +2,462 @@ package gocache
 
 import (
 	"GoCache/gocache/lru"
+	"GoCache/gocache/policy"
+	"math/rand"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// 实例化lru，封装get和add方法，并添加互斥锁mutex
+const (
+	// 后台定期删除过期记录的时间间隔。
+	sweepInterval = time.Second
+	// 每轮定期删除抽查的记录数。
+	sweepSamples = 20
+	// 默认的分片数量。
+	defaultShards = 16
+	// 每个分片最少分到的内存。容量较小时减少分片数量，避免单个分片的容量太小，存不下稍大一点的缓存值。
+	minShardBytes = 64 << 10
+)
+
+// cache 将缓存按照key的哈希值分成多个分片，每个分片拥有独立的互斥锁和lru实例。
+// 即使是get也会修改lru的链表（将节点移动到队尾），只能加互斥锁，分片后不同分片上的读写可以并行。
 type cache struct {
+	shards    []*cacheShard
+	sweepOnce sync.Once     // 保证后台定期删除协程只启动一次。
+	used      atomic.Int64  // 所有分片已经使用的内存，不需要加锁就可以读取，用于共享内存池。
+	next      atomic.Uint32 // 下一次由外部触发淘汰时从哪个分片开始。
+}
+
+// 记录被移除时的回调函数，在持有分片的锁时被调用。
+type evictFunc func(key string, value ByteView, reason lru.EvictReason)
+
+// 实例化lru，封装get和add方法，并添加互斥锁mutex
+type cacheShard struct {
 	mu         sync.Mutex
-	lru        *lru.Cache
+	store      store // 默认是lru.TypedCache，开启WithSlabStorage时是slabStore。
 	cacheBytes int64
+	slabSize   int64                        // 大于0时使用slabStore，淘汰策略固定为FIFO。
+	newPolicy  func() policy.Policy[string] // 淘汰策略的构造函数，为nil时使用LRU。
+	onEvict    evictFunc                    // 记录被移除时的回调函数，可以为nil。
+	maxEntries int                          // 最大缓存项数量，0代表不限制。
+	clock      func() int64                 // 用于记录缓存项的访问时间，为nil时不记录。
+	used       *atomic.Int64                // 指向cache.used。
+	bytes      int64                        // 上一次同步到used时lru已经使用的内存。
+	// 统计数据，在持有锁时更新。
+	gets, hits, evictions int64
+	_                     [64]byte // 填充到不同的CPU缓存行，避免相邻分片之间的伪共享。
+}
+
+// CacheType 缓存的类型，用于Group.CacheStats。
+type CacheType int
+
+const (
+	// MainCache 保存本节点负责的key（以及从远程节点获取失败后本地加载的key）的缓存。
+	MainCache CacheType = iota + 1
+	// HotCache 保存从远程节点获取的热点key的缓存，避免每次都访问远程节点。
+	HotCache
+)
+
+// CacheStats 缓存的统计数据。
+type CacheStats struct {
+	Bytes     int64 // 已经使用的内存。
+	Items     int64 // 缓存项的数量。
+	Gets      int64 // 查询次数。
+	Hits      int64 // 命中次数。
+	Evictions int64 // 被淘汰（包括过期）的缓存项数量。
+}
+
+// 实例化cache，cacheBytes由各个分片平分。shards <= 0时使用默认的分片数量。
+// 每个分片使用newPolicy创建独立的淘汰策略，newPolicy为nil时使用LRU。
+func newCache(cacheBytes int64, shards int, newPolicy func() policy.Policy[string]) *cache {
+	if shards <= 0 {
+		shards = autoShards(cacheBytes)
+	}
+	c := &cache{shards: make([]*cacheShard, shards)}
+	for i := range c.shards {
+		c.shards[i] = &cacheShard{cacheBytes: c.shardBytes(cacheBytes, i), newPolicy: newPolicy, used: &c.used}
+	}
+	return c
+}
+
+// 根据缓存容量自动选择分片数量，保证每个分片至少有minShardBytes的容量。
+func autoShards(cacheBytes int64) int {
+	shards := defaultShards
+	if cacheBytes > 0 && cacheBytes/minShardBytes < int64(shards) {
+		shards = int(cacheBytes / minShardBytes)
+	}
+	if shards < 1 {
+		shards = 1
+	}
+	return shards
+}
+
+// 设置记录被移除时的回调函数，需要在使用cache之前调用。
+func (c *cache) setOnEvict(fn evictFunc) {
+	for _, s := range c.shards {
+		s.onEvict = fn
+	}
+}
+
+// 设置最大缓存项数量，由各个分片平分，需要在使用cache之前调用。除不尽的部分分给前面的分片。
+// 分片的maxEntries为0代表不限制，所以n小于分片数量时每个分片至少限制为1个缓存项。
+func (c *cache) setMaxEntries(n int) {
+	if n <= 0 {
+		return
+	}
+	shards := len(c.shards)
+	for i, s := range c.shards {
+		s.maxEntries = n / shards
+		if i < n%shards || s.maxEntries == 0 {
+			s.maxEntries++
+		}
+	}
+}
+
+// 使用slabStore保存缓存项，slabSize是每块slab的大小，需要在使用cache之前调用。
+func (c *cache) setSlabSize(slabSize int64) {
+	for _, s := range c.shards {
+		s.slabSize = slabSize
+	}
+}
+
+// 设置记录缓存项访问时间的时钟，需要在使用cache之前调用。
+func (c *cache) setClock(clock func() int64) {
+	for _, s := range c.shards {
+		s.clock = clock
+	}
+}
+
+// 将cacheBytes平分给各个分片，返回第i个分片的容量。除不尽的部分分给第一个分片，保证总容量不变。
+// 分片容量为0代表不限制，所以cacheBytes小于分片数量时每个分片至少分到1字节，总容量最多多出分片数量的字节。
+func (c *cache) shardBytes(cacheBytes int64, i int) int64 {
+	if cacheBytes <= 0 {
+		return cacheBytes
+	}
+	n := int64(len(c.shards))
+	if cacheBytes < n {
+		return 1
+	}
+	if i == 0 {
+		return cacheBytes/n + cacheBytes%n
+	}
+	return cacheBytes / n
+}
+
+// 根据key的哈希值（FNV-1a）选择分片。
+func (c *cache) shard(key string) *cacheShard {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return c.shards[h%uint32(len(c.shards))]
+}
+
+// expire为零值代表永不过期。
+func (c *cache) add(key string, value ByteView, expire time.Time) {
+	if !expire.IsZero() {
+		// 第一次添加会过期的记录时，才启动后台定期删除协程。
+		c.sweepOnce.Do(func() {
+			go c.sweep()
+		})
+	}
+	c.shard(key).add(key, value, expire)
+}
+
+func (c *cache) get(key string) (value ByteView, ok bool) {
+	return c.shard(key).get(key)
+}
+
+func (c *cache) remove(key string) {
+	c.shard(key).remove(key)
+}
+
+// 查找key，不影响淘汰顺序和统计数据。
+func (c *cache) peek(key string) (value ByteView, ok bool) {
+	return c.shard(key).peek(key)
+}
+
+// 返回所有的key。每个分片内越晚被淘汰的key越靠前，分片之间没有顺序。
+func (c *cache) keys() []string {
+	var keys []string
+	for _, s := range c.shards {
+		keys = append(keys, s.keys()...)
+	}
+	return keys
+}
+
+// 遍历所有的记录，fn返回false时停止遍历。遍历时持有分片的锁，fn中不能访问cache。
+func (c *cache) rangeAll(fn func(key string, value ByteView) bool) {
+	for _, s := range c.shards {
+		if !s.rangeAll(fn) {
+			return
+		}
+	}
+}
+
+// 修改缓存容量，由各个分片平分，超出新容量的记录会被淘汰。
+func (c *cache) resize(cacheBytes int64) {
+	for i, s := range c.shards {
+		s.resize(c.shardBytes(cacheBytes, i))
+	}
+}
+
+// 删除所有的记录。
+func (c *cache) clear() {
+	for _, s := range c.shards {
+		s.clear()
+	}
+}
+
+// 返回已经使用的内存。
+func (c *cache) bytes() int64 {
+	return c.used.Load()
+}
+
+// 按照淘汰策略淘汰一条记录，依次尝试各个分片，返回是否淘汰成功。
+func (c *cache) evictOne() bool {
+	n := uint32(len(c.shards))
+	start := c.next.Add(1)
+	for i := uint32(0); i < n; i++ {
+		if c.shards[(start+i)%n].removeOldest() {
+			return true
+		}
+	}
+	return false
+}
+
+// 在随机选择的一个非空分片中抽查samples条记录，返回其中最久没有被访问的记录。需要设置时钟。
+func (c *cache) sampleOldest(samples int) (key string, atime int64, ok bool) {
+	n := len(c.shards)
+	start := rand.Intn(n)
+	for i := 0; i < n; i++ {
+		if key, atime, ok = c.shards[(start+i)%n].sampleOldest(samples); ok {
+			return
+		}
+	}
+	return
+}
+
+// 以容量不足的原因淘汰key。
+func (c *cache) evict(key string) bool {
+	return c.shard(key).evict(key)
+}
+
+// 汇总所有分片的统计数据。
+func (c *cache) stats() CacheStats {
+	var st CacheStats
+	for _, s := range c.shards {
+		s.mu.Lock()
+		if s.store != nil {
+			st.Bytes += s.store.Bytes()
+			st.Items += int64(s.store.Len())
+		}
+		st.Gets += s.gets
+		st.Hits += s.hits
+		st.Evictions += s.evictions
+		s.mu.Unlock()
+	}
+	return st
+}
+
+// 定期删除：惰性删除只能清理被访问到的过期记录，不再被访问的过期记录需要后台协程主动清理，才能回收内存。
+// 每轮在每个分片上抽查sweepSamples条记录，如果其中超过1/4已经过期，说明过期记录较多，立即开始下一轮抽查。
+// Group的生命周期与进程一致，所以该协程不会退出。
+func (c *cache) sweep() {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, s := range c.shards {
+			for {
+				if removed := s.removeExpired(); removed <= sweepSamples/4 {
+					break
+				}
+			}
+		}
+	}
 }
 
 // 可以优化为单例初始化.....
-func (c *cache) add(key string, value ByteView) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.lru == nil {
-		// 如果c.lru为nil，再创建lru实例。
+func (s *cacheShard) add(key string, value ByteView, expire time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.account()
+	if s.store == nil {
+		// 如果s.store为nil，再创建存储实例。
 		// 延迟初始化：一个对象的延迟初始化意味着该对象的创建将会延迟到第一次使用该对象时，主要用于提高性能，减少程序内存要求。
-		c.lru = lru.New(c.cacheBytes, nil)
+		s.store = s.newStore()
 	}
-	c.lru.Add(key, value)
+	s.store.AddWithExpire(key, value, expire)
 }
 
-func (c *cache) get(key string) (value ByteView, ok bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.lru == nil {
+// 创建分片的存储。设置了slabSize并且容量大于0时使用slabStore，否则使用lru.TypedCache。
+func (s *cacheShard) newStore() store {
+	if s.slabSize > 0 && s.cacheBytes > 0 {
+		st := newSlabStore(s.cacheBytes, s.slabSize, s.onEvicted)
+		st.c.MaxEntries = s.maxEntries
+		st.c.Clock = s.clock
+		return st
+	}
+	var c *lru.TypedCache[string, ByteView]
+	if s.newPolicy != nil {
+		c = lru.NewCacheWithPolicy(s.cacheBytes, entrySize, s.onEvicted, s.newPolicy())
+	} else {
+		c = lru.NewCache(s.cacheBytes, entrySize, s.onEvicted)
+	}
+	c.MaxEntries = s.maxEntries
+	c.Clock = s.clock
+	return c
+}
+
+func (s *cacheShard) get(key string) (value ByteView, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.account()
+	s.gets++
+	if s.store == nil {
 		return
 	}
 
-	if v, ok := c.lru.Get(key); ok {
-		return v.(ByteView), ok
+	if v, ok := s.store.Get(key); ok {
+		s.hits++
+		return v, ok
 	}
 
 	return
 }
+
+func (s *cacheShard) remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.account()
+	if s.store != nil {
+		s.store.Remove(key)
+	}
+}
+
+func (s *cacheShard) peek(key string) (value ByteView, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.store == nil {
+		return
+	}
+	return s.store.Peek(key)
+}
+
+func (s *cacheShard) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.store == nil {
+		return nil
+	}
+	return s.store.Keys()
+}
+
+// 返回false代表fn要求停止遍历。
+func (s *cacheShard) rangeAll(fn func(key string, value ByteView) bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.store == nil {
+		return true
+	}
+	more := true
+	s.store.Range(func(key string, value ByteView) bool {
+		more = fn(key, value)
+		return more
+	})
+	return more
+}
+
+func (s *cacheShard) resize(cacheBytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.account()
+	s.cacheBytes = cacheBytes
+	if s.store != nil {
+		s.store.Resize(cacheBytes)
+	}
+}
+
+func (s *cacheShard) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.account()
+	if s.store != nil {
+		s.store.Clear()
+	}
+}
+
+func (s *cacheShard) removeOldest() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.account()
+	// slabStore中可能只剩下已经被删除、还没有回收的记录，此时RemoveOldest同样可以回收内存。
+	if s.store == nil || s.store.Bytes() == 0 {
+		return false
+	}
+	s.store.RemoveOldest()
+	return true
+}
+
+func (s *cacheShard) sampleOldest(samples int) (key string, atime int64, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.store == nil {
+		return
+	}
+	return s.store.SampleOldest(samples)
+}
+
+func (s *cacheShard) evict(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.account()
+	return s.store != nil && s.store.Evict(key)
+}
+
+// 将lru已经使用内存的变化同步到cache.used，在修改lru之后、释放锁之前调用。
+func (s *cacheShard) account() {
+	if s.store != nil {
+		n := s.store.Bytes()
+		s.used.Add(n - s.bytes)
+		s.bytes = n
+	}
+}
+
+// 每个缓存项的固定开销，包括lru中的entry、链表节点、字典槽位，以及ByteView的切片头和过期时间。
+var entryOverhead = lru.EntryOverhead[string, ByteView]()
+
+// 缓存项占用的内存大小，包括key和value的内容以及固定开销，使cacheBytes接近实际占用的堆内存。
+// key和value的内容按照8字节对齐，与内存分配器的最小规格一致。
+func entrySize(key string, value ByteView) int64 {
+	return align8(len(key)) + align8(value.Len()) + entryOverhead
+}
+
+func align8(n int) int64 {
+	return int64((n + 7) &^ 7)
+}
+
+// lru的回调函数，在持有锁时被调用。
+func (s *cacheShard) onEvicted(key string, value ByteView, reason lru.EvictReason) {
+	if reason == lru.EvictCapacity || reason == lru.EvictExpired {
+		s.evictions++
+	}
+	if s.onEvict != nil {
+		s.onEvict(key, value, reason)
+	}
+}
+
+func (s *cacheShard) removeExpired() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.account()
+	if s.store == nil {
+		return 0
+	}
+	return s.store.RemoveExpired(sweepSamples)
+}
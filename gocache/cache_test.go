@@ -0,0 +1,219 @@
+package gocache
+
+import (
+	"GoCache/gocache/lru"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCacheShards(t *testing.T) {
+	c := newCache(1<<20, 8, nil)
+	var total int64
+	for _, s := range c.shards {
+		total += s.cacheBytes
+	}
+	if len(c.shards) != 8 || total != 1<<20 {
+		t.Fatalf("expected 8 shards sharing 1MB, got %d shards with %d bytes", len(c.shards), total)
+	}
+
+	for i := 0; i < 100; i++ {
+		k := strconv.Itoa(i)
+		c.add(k, ByteView{b: []byte(k)}, time.Time{})
+	}
+	for i := 0; i < 100; i++ {
+		k := strconv.Itoa(i)
+		if v, ok := c.get(k); !ok || v.String() != k {
+			t.Fatalf("cache hit %s failed", k)
+		}
+	}
+
+	// 容量较小时自动减少分片数量。
+	if c := newCache(2<<10, 0, nil); len(c.shards) != 1 {
+		t.Fatalf("expected 1 shard for small cache, got %d", len(c.shards))
+	}
+
+	// 容量小于分片数量时，每个分片仍然有容量限制，而不是不限制。
+	c = newCache(4, 8, nil)
+	for _, s := range c.shards {
+		if s.cacheBytes != 1 {
+			t.Fatalf("expected 1 byte per shard, got %d", s.cacheBytes)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		k := strconv.Itoa(i)
+		c.add(k, ByteView{b: []byte(k)}, time.Time{})
+	}
+	if n := c.stats().Items; n != 0 {
+		t.Fatalf("expected tiny cache to reject entries, got %d items", n)
+	}
+}
+
+// 对比单个分片（相当于全局互斥锁）和多个分片在并发读场景下的性能。
+// go test -bench=CacheGetParallel -cpu=1,4,8
+func BenchmarkCacheGetParallel(b *testing.B) {
+	for _, shards := range []int{1, defaultShards} {
+		b.Run("shards="+strconv.Itoa(shards), func(b *testing.B) {
+			benchmarkCacheGetParallel(b, shards)
+		})
+	}
+}
+
+func benchmarkCacheGetParallel(b *testing.B, shards int) {
+	const keys = 1024
+	c := newCache(0, shards, nil)
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = strconv.Itoa(i)
+		c.add(names[i], ByteView{b: []byte(names[i])}, time.Time{})
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		// 每个协程从随机位置开始，避免所有协程同时访问同一个分片。
+		i := rand.Intn(keys)
+		for pb.Next() {
+			c.get(names[i%keys])
+			i++
+		}
+	})
+}
+
+func TestCacheSlabStorage(t *testing.T) {
+	var evicted []ByteView
+	c := newCache(1<<10, 1, nil)
+	c.setSlabSize(1 << 10)
+	c.setOnEvict(func(key string, value ByteView, reason lru.EvictReason) {
+		evicted = append(evicted, value)
+	})
+
+	stale := time.Now().Add(time.Minute).Round(0)
+	c.add("Tom", ByteView{b: []byte("630"), stale: stale, expire: stale.Add(time.Minute)}, time.Time{})
+	v, ok := c.get("Tom")
+	if !ok || v.String() != "630" || !v.stale.Equal(stale) || !v.expire.Equal(stale.Add(time.Minute)) {
+		t.Fatalf("unexpected value %+v", v)
+	}
+
+	// 容量1KB，写满之后按照写入顺序淘汰，回调函数收到的值不受之后写入的影响。
+	for i := 0; i < 100; i++ {
+		c.add(strconv.Itoa(i), ByteView{b: []byte(fmt.Sprintf("value%02d", i))}, time.Time{})
+	}
+	if c.bytes() > 1<<10 || len(evicted) == 0 || evicted[0].String() != "630" || evicted[1].String() != "value00" {
+		t.Fatalf("unexpected evictions, bytes %d", c.bytes())
+	}
+	if _, ok := c.get("Tom"); ok {
+		t.Fatal("Tom should be evicted")
+	}
+	if v, ok := c.get("99"); !ok || v.String() != "value99" {
+		t.Fatalf("unexpected value %q", v)
+	}
+	if keys := c.keys(); len(keys) == 0 || keys[0] != "99" {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+
+	// 容量<= 0时保持原来的容量，不能panic。
+	n := c.stats().Items
+	c.resize(0)
+	if c.stats().Items != n {
+		t.Fatalf("resize to 0 should keep %d items, got %d", n, c.stats().Items)
+	}
+	if v, ok := c.get("99"); !ok || v.String() != "value99" {
+		t.Fatalf("unexpected value %q after resize", v)
+	}
+}
+
+// 对比lru和slab两种存储在一百万个小缓存项时的GC耗时。ns/op是一次完整GC的耗时，pause-ns/gc是STW暂停的时长。
+// go test -run=NONE -bench=CacheGC -benchtime=20x
+func BenchmarkCacheGC(b *testing.B) {
+	for _, slabSize := range []int64{0, 1 << 20} {
+		name := "lru"
+		if slabSize > 0 {
+			name = "slab"
+		}
+		b.Run(name, func(b *testing.B) {
+			c := newBenchmarkCache(slabSize, 1000000)
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+			}
+			b.StopTimer()
+			runtime.ReadMemStats(&after)
+			b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/float64(after.NumGC-before.NumGC), "pause-ns/gc")
+			b.ReportMetric(float64(after.HeapAlloc)/(1<<20), "heap-MB")
+			runtime.KeepAlive(c)
+		})
+	}
+}
+
+// 对比lru和slab两种存储的读写吞吐，90%读、10%写。
+// go test -run=NONE -bench=CacheGetAdd -cpu=1,4
+func BenchmarkCacheGetAdd(b *testing.B) {
+	const keys = 100000
+	for _, slabSize := range []int64{0, 1 << 20} {
+		name := "lru"
+		if slabSize > 0 {
+			name = "slab"
+		}
+		b.Run(name, func(b *testing.B) {
+			c := newBenchmarkCache(slabSize, keys)
+			value := ByteView{b: []byte("0123456789abcdef")}
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				r := rand.New(rand.NewSource(rand.Int63()))
+				for pb.Next() {
+					key := "key" + strconv.Itoa(r.Intn(keys))
+					if r.Intn(10) == 0 {
+						c.add(key, value, time.Time{})
+					} else {
+						c.get(key)
+					}
+				}
+			})
+		})
+	}
+}
+
+// 创建容量足够保存n个小缓存项的cache，slabSize > 0时使用slab存储。
+func newBenchmarkCache(slabSize int64, n int) *cache {
+	c := newCache(int64(n)*(entryOverhead+64), 0, nil)
+	c.setSlabSize(slabSize)
+	value := ByteView{b: []byte("0123456789abcdef")}
+	for i := 0; i < n; i++ {
+		c.add("key"+strconv.Itoa(i), value, time.Time{})
+	}
+	return c
+}
+
+// 比较一百万个小缓存项的统计内存和实际占用的堆内存。
+func TestCacheMemoryAccounting(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping memory accounting test in short mode")
+	}
+	const n = 1000000
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	c := newCache(0, 1, nil)
+	for i := 0; i < n; i++ {
+		key := "key" + strconv.Itoa(i)
+		c.add(key, ByteView{b: []byte(strconv.Itoa(i % 1000))}, time.Time{})
+	}
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	heap := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+	reported := c.stats().Bytes
+	runtime.KeepAlive(c)
+
+	t.Logf("reported %d bytes, heap %d bytes, %.1f bytes per entry", reported, heap, float64(heap)/n)
+	if ratio := float64(reported) / float64(heap); ratio < 0.8 || ratio > 1.2 {
+		t.Fatalf("reported size %d is not close to heap size %d (ratio %.2f)", reported, heap, ratio)
+	}
+}
@@ -0,0 +1,211 @@
+package gocache
+
+import (
+	"log"
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// CapacityConfig CapacityController的参数，零值字段使用默认值。
+type CapacityConfig struct {
+	Interval     time.Duration // 采样间隔，默认1秒。
+	Limit        uint64        // 内存上限，0代表使用GOMEMLIMIT（debug.SetMemoryLimit），两者都没有设置时不做调整。
+	HighWater    float64       // 已使用内存超过Limit的该比例时缩小缓存容量，默认0.85。
+	LowWater     float64       // 已使用内存低于Limit的该比例时恢复缓存容量，默认0.7。
+	ShrinkFactor float64       // 每次缩小时容量乘以的系数，默认0.8。
+	GrowFactor   float64       // 每次恢复时容量乘以的系数，默认1.1，最多恢复到初始容量。
+	MinScale     float64       // 容量最多缩小到初始容量的该比例，默认0.1。
+}
+
+// CapacityStats CapacityController的决策记录。
+type CapacityStats struct {
+	Scale      float64   // 当前容量相对于初始容量的比例。
+	Used       uint64    // 最近一次采样时Go运行时使用的内存。
+	Limit      uint64    // 最近一次采样时的内存上限，0代表没有上限。
+	Shrinks    int64     // 缩小容量的次数。
+	Grows      int64     // 恢复容量的次数。
+	LastChange time.Time // 最近一次调整容量的时间。
+}
+
+// CapacityController 根据Go运行时的内存压力自动调整缓存容量：定期通过runtime/metrics采样已使用的内存，
+// 超过上限的HighWater时按比例缩小所有Group（以及MemoryPool）的容量，超出新容量的缓存项会被淘汰；
+// 压力降到LowWater以下时逐步恢复，最多恢复到初始容量。适用于设置了GOMEMLIMIT的服务，
+// 避免堆内存接近上限时缓存仍然占用同样多的内存，导致GC频繁运行。
+// 加入CapacityController之后，不要再手动调用Group.Resize或者MemoryPool.SetLimit，否则会被下一次调整覆盖。
+type CapacityController struct {
+	cfg        CapacityConfig
+	readMemory func() (used, limit uint64) // 读取已使用的内存和内存上限，测试时可以替换。
+
+	mu      sync.Mutex
+	scale   float64
+	targets []capacityTarget
+	stats   CapacityStats
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// 被调整容量的对象，以及它的初始容量。
+type capacityTarget struct {
+	base   int64
+	group  *Group // 调整容量时更新group的统计数据，为nil代表MemoryPool。
+	resize func(int64)
+}
+
+// runtime/metrics中的指标。GOMEMLIMIT限制的是Go运行时映射的全部内存减去已经归还给操作系统的部分。
+var capacityMetrics = []string{
+	"/memory/classes/total:bytes",
+	"/memory/classes/heap/released:bytes",
+}
+
+// NewCapacityController 实例化CapacityController，需要调用Start启动后台采样。
+func NewCapacityController(cfg CapacityConfig) *CapacityController {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.HighWater <= 0 {
+		cfg.HighWater = 0.85
+	}
+	if cfg.LowWater <= 0 || cfg.LowWater > cfg.HighWater {
+		cfg.LowWater = math.Min(0.7, cfg.HighWater)
+	}
+	if cfg.ShrinkFactor <= 0 || cfg.ShrinkFactor >= 1 {
+		cfg.ShrinkFactor = 0.8
+	}
+	if cfg.GrowFactor <= 1 {
+		cfg.GrowFactor = 1.1
+	}
+	if cfg.MinScale <= 0 || cfg.MinScale > 1 {
+		cfg.MinScale = 0.1
+	}
+	c := &CapacityController{cfg: cfg, scale: 1, stop: make(chan struct{})}
+	c.stats.Scale = 1
+	c.readMemory = c.runtimeMemory
+	return c
+}
+
+// 通过runtime/metrics读取已使用的内存，以及内存上限。
+func (c *CapacityController) runtimeMemory() (used, limit uint64) {
+	samples := make([]metrics.Sample, len(capacityMetrics))
+	for i, name := range capacityMetrics {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+	if samples[0].Value.Kind() == metrics.KindUint64 && samples[1].Value.Kind() == metrics.KindUint64 {
+		used = samples[0].Value.Uint64() - samples[1].Value.Uint64()
+	}
+
+	limit = c.cfg.Limit
+	if limit == 0 {
+		// 传入负数只读取当前的GOMEMLIMIT，不修改。没有设置时是math.MaxInt64。
+		if l := debug.SetMemoryLimit(-1); l > 0 && l != math.MaxInt64 {
+			limit = uint64(l)
+		}
+	}
+	return used, limit
+}
+
+// 加入Group，初始容量是NewGroup时mainCache和hotCache的容量。容量为0（不限制）的缓存不做调整。
+func (c *CapacityController) addGroup(g *Group, mainBytes, hotBytes int64) {
+	c.add(capacityTarget{base: mainBytes, group: g, resize: func(n int64) { g.Resize(MainCache, n) }})
+	c.add(capacityTarget{base: hotBytes, group: g, resize: func(n int64) { g.Resize(HotCache, n) }})
+}
+
+// AddPool 加入MemoryPool，初始容量是当前的Limit()。
+func (c *CapacityController) AddPool(p *MemoryPool) {
+	c.add(capacityTarget{base: p.Limit(), resize: p.SetLimit})
+}
+
+func (c *CapacityController) add(t capacityTarget) {
+	if t.base <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.targets = append(c.targets, t)
+	if c.scale < 1 {
+		t.resize(scaleBytes(t.base, c.scale))
+	}
+}
+
+// Start 启动后台协程，每隔Interval采样一次并调整容量。
+func (c *CapacityController) Start() {
+	go func() {
+		ticker := time.NewTicker(c.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.adjust()
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台协程，已经调整的容量保持不变。
+func (c *CapacityController) Stop() {
+	c.stopOnce.Do(func() { close(c.stop) })
+}
+
+// Stats 返回决策记录的快照。
+func (c *CapacityController) Stats() CapacityStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// 采样一次内存压力，需要时按比例调整所有对象的容量。
+func (c *CapacityController) adjust() {
+	used, limit := c.readMemory()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Used, c.stats.Limit = used, limit
+	if limit == 0 {
+		return
+	}
+	pressure := float64(used) / float64(limit)
+	scale := c.scale
+	switch {
+	case pressure > c.cfg.HighWater && scale > c.cfg.MinScale:
+		scale = math.Max(scale*c.cfg.ShrinkFactor, c.cfg.MinScale)
+		c.stats.Shrinks++
+	case pressure < c.cfg.LowWater && scale < 1:
+		scale = math.Min(scale*c.cfg.GrowFactor, 1)
+		c.stats.Grows++
+	default:
+		return
+	}
+	log.Printf("[GoCache] memory pressure %.2f, scale cache capacity %.2f -> %.2f", pressure, c.scale, scale)
+	shrink := scale < c.scale
+	c.scale = scale
+	c.stats.Scale = scale
+	c.stats.LastChange = time.Now()
+
+	counted := make(map[*Group]bool)
+	for _, t := range c.targets {
+		t.resize(scaleBytes(t.base, scale))
+		if t.group == nil || counted[t.group] {
+			continue
+		}
+		counted[t.group] = true
+		if shrink {
+			t.group.stats.CapacityShrinks.Add(1)
+		} else {
+			t.group.stats.CapacityGrows.Add(1)
+		}
+	}
+}
+
+// 按比例缩放容量，至少保留1字节，避免变成0（不限制）。
+func scaleBytes(base int64, scale float64) int64 {
+	if n := int64(float64(base) * scale); n > 0 {
+		return n
+	}
+	return 1
+}
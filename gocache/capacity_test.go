@@ -0,0 +1,85 @@
+package gocache
+
+import (
+	"testing"
+)
+
+func TestCapacityController(t *testing.T) {
+	var used uint64
+	c := NewCapacityController(CapacityConfig{Limit: 100, ShrinkFactor: 0.5, GrowFactor: 2, MinScale: 0.25})
+	c.readMemory = func() (uint64, uint64) { return used, 100 }
+
+	size := entrySize("k0", ByteView{b: []byte("vk0")})
+	group := NewGroup("capacity", 8*size, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte("v" + key), nil
+		}), WithShards(1), WithCapacityController(c))
+	pool := NewMemoryPool(8*size, PoolWeighted)
+	c.AddPool(pool)
+	fillGroup(group, "k", 8)
+
+	// 没有压力时不调整。
+	used = 50
+	c.adjust()
+	if st := c.Stats(); st.Scale != 1 || st.Shrinks != 0 || st.Used != 50 || st.Limit != 100 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+
+	used = 90
+	c.adjust()
+	if st := c.Stats(); st.Scale != 0.5 || st.Shrinks != 1 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+	if n := group.CacheStats(MainCache).Items; n != 4 {
+		t.Fatalf("expected 4 items after shrinking, got %d", n)
+	}
+	if pool.Limit() != 4*size {
+		t.Fatalf("expected pool limit %d, got %d", 4*size, pool.Limit())
+	}
+	c.adjust()
+	c.adjust()
+	if st := c.Stats(); st.Scale != 0.25 || st.Shrinks != 2 {
+		t.Fatalf("scale should stop at MinScale, got %+v", st)
+	}
+
+	// 在HighWater和LowWater之间保持不变。
+	used = 80
+	c.adjust()
+	if c.Stats().Grows != 0 {
+		t.Fatalf("unexpected grow %+v", c.Stats())
+	}
+
+	used = 10
+	c.adjust()
+	c.adjust()
+	c.adjust()
+	if st := c.Stats(); st.Scale != 1 || st.Grows != 2 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+	fillGroup(group, "k", 8)
+	if n := group.CacheStats(MainCache).Items; n != 8 {
+		t.Fatalf("expected 8 items after growing, got %d", n)
+	}
+	if s := group.Stats(); s.CapacityShrinks.Get() != 2 || s.CapacityGrows.Get() != 2 {
+		t.Fatalf("unexpected group stats %+v", s)
+	}
+}
+
+func TestCapacityControllerNoLimit(t *testing.T) {
+	c := NewCapacityController(CapacityConfig{})
+	c.readMemory = func() (uint64, uint64) { return 1 << 30, 0 }
+	c.adjust()
+	if st := c.Stats(); st.Scale != 1 || st.Shrinks != 0 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+}
+
+func TestCapacityControllerRuntime(t *testing.T) {
+	c := NewCapacityController(CapacityConfig{Limit: 1 << 50})
+	if used, limit := c.runtimeMemory(); used == 0 || limit != 1<<50 {
+		t.Fatalf("unexpected runtime memory %d, %d", used, limit)
+	}
+	c.Start()
+	c.Stop()
+	c.Stop()
+}
@@ -68,3 +68,27 @@ func (m *Map) Get(key string) string {
 	// 通过hashMap映射得到真实的节点。
 	return m.hashMap[m.keys[idx%len(m.keys)]]
 }
+
+// GetN 按照哈希环顺时针的顺序，返回负责key的前n个不同的真实节点。第一个节点与Get的结果相同，
+// 之后的节点是key的后继节点，例如负责的节点不可用时依次尝试。真实节点少于n个时返回全部节点。
+func (m *Map) GetN(key string, n int) []string {
+	if len(m.keys) == 0 || n <= 0 {
+		return nil
+	}
+	hash := int(m.hash([]byte(key)))
+	idx := sort.Search(len(m.keys), func(i int) bool {
+		return m.keys[i] >= hash
+	})
+
+	nodes := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	// 最多绕哈希环一圈，跳过同一个真实节点的其他虚拟节点。
+	for i := 0; i < len(m.keys) && len(nodes) < n; i++ {
+		node := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if !seen[node] {
+			seen[node] = true
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
@@ -1,6 +1,7 @@
 package consistenthash
 
 import (
+	"reflect"
 	"strconv"
 	"testing"
 )
@@ -36,3 +37,33 @@ func TestHashing(t *testing.T) {
 	}
 
 }
+
+func TestGetN(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, _ := strconv.Atoi(string(key))
+		return uint32(i)
+	})
+	// 虚拟节点：2, 4, 6, 12, 14, 16, 22, 24, 26。
+	hash.Add("6", "4", "2")
+
+	testCases := map[string][]string{
+		"2":  {"2", "4", "6"},
+		"11": {"2", "4", "6"},
+		"23": {"4", "6", "2"},
+		"27": {"2", "4", "6"},
+	}
+	for k, v := range testCases {
+		if got := hash.GetN(k, 3); !reflect.DeepEqual(got, v) {
+			t.Errorf("Asking for %s, should have yieled %v, got %v", k, v, got)
+		}
+		if got := hash.GetN(k, 1); got[0] != hash.Get(k) {
+			t.Errorf("GetN(%s, 1) = %v, Get = %s", k, got, hash.Get(k))
+		}
+	}
+	if got := hash.GetN("23", 10); len(got) != 3 {
+		t.Errorf("expected all 3 nodes, got %v", got)
+	}
+	if got := New(3, nil).GetN("23", 2); got != nil {
+		t.Errorf("expected no nodes for empty ring, got %v", got)
+	}
+}
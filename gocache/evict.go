@@ -0,0 +1,43 @@
+package gocache
+
+import "GoCache/gocache/lru"
+
+// EvictReason 缓存项被移除的原因。
+type EvictReason = lru.EvictReason
+
+const (
+	EvictCapacity = lru.EvictCapacity // 超过缓存容量，被淘汰策略淘汰。
+	EvictExpired  = lru.EvictExpired  // 缓存项过期。
+	EvictRemoved  = lru.EvictRemoved  // 被Remove删除。
+	EvictReplaced = lru.EvictReplaced // 被新值替换，回调函数收到的是旧值。
+	EvictCleared  = lru.EvictCleared  // 被Clear清空。
+)
+
+// EvictionListener 缓存项被移除（或者被替换）时的回调函数，which代表缓存项所在的缓存。
+// 回调函数在持有缓存分片的锁时被同步调用，应当尽快返回，并且不能访问同一个Group的缓存（例如调用Get），否则会死锁。
+// 需要做耗时的操作（例如write-behind写回数据库）时，应当将其交给其他协程。
+type EvictionListener func(which CacheType, key string, value ByteView, reason EvictReason)
+
+// AddEvictionListener 注册缓存项被移除时的回调函数，例如用于统计指标或者write-behind。可以在Group使用过程中注册。
+func (g *Group) AddEvictionListener(fn EvictionListener) {
+	g.listenersMu.Lock()
+	defer g.listenersMu.Unlock()
+	var listeners []EvictionListener
+	if old := g.listeners.Load(); old != nil {
+		listeners = append(listeners, *old...)
+	}
+	// 写时复制，通知时不需要加锁。
+	listeners = append(listeners, fn)
+	g.listeners.Store(&listeners)
+}
+
+// 返回通知which缓存中缓存项被移除的回调函数。
+func (g *Group) notifyEvicted(which CacheType) evictFunc {
+	return func(key string, value ByteView, reason lru.EvictReason) {
+		if listeners := g.listeners.Load(); listeners != nil {
+			for _, fn := range *listeners {
+				fn(which, key, value, reason)
+			}
+		}
+	}
+}
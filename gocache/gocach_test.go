@@ -1,10 +1,18 @@
 package gocache
 
 import (
+	pb "GoCache/gocachepb"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestGetter(t *testing.T) {
@@ -16,7 +24,7 @@ func TestGetter(t *testing.T) {
 
 	expect := []byte("key")
 	// 调用该接口的方法 f.Get(key string)，实际上在调用匿名回调函数。
-	if v, _ := f.Get("key"); !reflect.DeepEqual(v, expect) {
+	if v, _ := f.Get(context.Background(), "key"); !reflect.DeepEqual(v, expect) {
 		t.Errorf("callback failed")
 	}
 }
@@ -56,21 +64,939 @@ func TestGet(t *testing.T) {
 	// 遍历本地数据库db。
 	for k, v := range db {
 		// 从分布式缓存Cache中获取value，如果未命中缓存，则调用回调函数从本地获取数据，并将数据添加到Cache中。
-		if view, err := group.Get(k); err != nil || view.String() != v {
+		if view, err := group.Get(context.Background(), k); err != nil || view.String() != v {
 			// 如果出现异常或者获取到的值value和本地数据库存储的值value不同。
 			t.Fatal("failed to get value of Tom")
 		}
 
 		// 再次获取，如果一个key不存在Cache中，通过上一步的调用，查询本地缓存后，就应该存在于Cache中，且loadCounts记录了该key。[key][1]
-		if _, err := group.Get(k); err != nil || loadCounts[k] > 1 {
+		if _, err := group.Get(context.Background(), k); err != nil || loadCounts[k] > 1 {
 			// 如果出现异常或者一个key调用回调函数大于1次。
 			t.Fatalf("cache %s miss", k)
 		}
 		// cache hit
 	}
 
-	if view, err := group.Get("unknown"); err == nil {
+	if view, err := group.Get(context.Background(), "unknown"); err == nil {
 		// 获取一个不存在的值，如果获取成功了。
 		t.Fatalf("the value of unknow should be empty, but %s got", view)
 	}
 }
+
+func TestGroupTTL(t *testing.T) {
+	loads := 0
+	group := NewGroup("ttl", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}), WithTTL(50*time.Millisecond))
+
+	for i := 0; i < 2; i++ {
+		if _, err := group.Get(context.Background(), "Tom"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("expected 1 load before expiration, got %d", loads)
+	}
+
+	// 缓存项过期后，再次获取需要重新调用回调函数。
+	time.Sleep(100 * time.Millisecond)
+	if _, err := group.Get(context.Background(), "Tom"); err != nil || loads != 2 {
+		t.Fatalf("expected reload after expiration, got %d loads, err %v", loads, err)
+	}
+}
+
+// 模拟远程节点，记录被访问的次数。err不为nil时模拟节点不可用，failures > 0时只有前failures次Get失败。
+type fakePeer struct {
+	gets     int
+	multis   [][]string
+	sets     map[string]string
+	removes  []string
+	err      error
+	failures int
+}
+
+func (p *fakePeer) Get(_ context.Context, in *pb.Request, out *pb.Response) error {
+	p.gets++
+	if p.err != nil && (p.failures == 0 || p.gets <= p.failures) {
+		return p.err
+	}
+	out.Value = []byte("peer:" + in.GetKey())
+	return nil
+}
+
+// 以"unknown"开头的key数据不存在。
+func (p *fakePeer) GetMulti(_ context.Context, in *pb.MultiRequest, out *pb.MultiResponse) error {
+	p.multis = append(p.multis, in.GetKeys())
+	if p.err != nil {
+		return p.err
+	}
+	out.Values = make(map[string][]byte)
+	for _, key := range in.GetKeys() {
+		if strings.HasPrefix(key, "unknown") {
+			out.Missing = append(out.Missing, key)
+			continue
+		}
+		out.Values[key] = []byte("peer:" + key)
+	}
+	return nil
+}
+
+func (p *fakePeer) Set(_ context.Context, in *pb.SetRequest, out *pb.Response) error {
+	if p.sets == nil {
+		p.sets = make(map[string]string)
+	}
+	p.sets[in.GetKey()] = string(in.GetValue())
+	return nil
+}
+
+func (p *fakePeer) Remove(_ context.Context, in *pb.Request, out *pb.Response) error {
+	p.removes = append(p.removes, in.GetKey())
+	return nil
+}
+
+// 所有的key都由远程节点peer负责，others是其他不负责任何key的远程节点。
+type fakePicker struct {
+	peer   *fakePeer
+	others []*fakePeer
+}
+
+func (p fakePicker) PickPeer(key string) (PeerGetter, bool) {
+	return p.peer, true
+}
+
+func (p fakePicker) GetAll() []PeerGetter {
+	all := []PeerGetter{p.peer}
+	for _, other := range p.others {
+		all = append(all, other)
+	}
+	return all
+}
+
+// 所有的key都由replicas[0]负责，之后是哈希环上的后继节点，nil代表本节点。
+type fakeReplicaPicker struct {
+	fakePicker
+	replicas []PeerGetter
+}
+
+func (p fakeReplicaPicker) PickReplicas(key string, n int) []PeerGetter {
+	if n > len(p.replicas) {
+		n = len(p.replicas)
+	}
+	return p.replicas[:n]
+}
+
+func TestHotCache(t *testing.T) {
+	group := NewGroup("hot", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return nil, fmt.Errorf("%s should be loaded from peer", key)
+		}), WithHotCache(1<<10, 1))
+	peer := &fakePeer{}
+	group.RegisterPeers(fakePicker{peer: peer})
+
+	for i := 0; i < 3; i++ {
+		if view, err := group.Get(context.Background(), "Tom"); err != nil || view.String() != "peer:Tom" {
+			t.Fatalf("failed to get Tom from peer: %v", err)
+		}
+	}
+	// 采样率为1，第一次从远程节点获取后就被添加到hotCache中。
+	if peer.gets != 1 {
+		t.Fatalf("expected 1 peer get, got %d", peer.gets)
+	}
+	if hot := group.CacheStats(HotCache); hot.Items != 1 || hot.Hits != 2 {
+		t.Fatalf("unexpected hot cache stats %+v", hot)
+	}
+	if main := group.CacheStats(MainCache); main.Items != 0 {
+		t.Fatalf("peer value should not be added to main cache, stats %+v", main)
+	}
+}
+
+func TestSetRemove(t *testing.T) {
+	loads := 0
+	group := NewGroup("set", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte("db:" + key), nil
+		}))
+
+	if err := group.Set(context.Background(), "Tom", []byte("700")); err != nil {
+		t.Fatal(err)
+	}
+	if view, err := group.Get(context.Background(), "Tom"); err != nil || view.String() != "700" || loads != 0 {
+		t.Fatalf("expected value written by Set, got %s, %d loads", view, loads)
+	}
+
+	// 删除后再次获取，需要调用回调函数。
+	if err := group.Remove(context.Background(), "Tom"); err != nil {
+		t.Fatal(err)
+	}
+	if view, err := group.Get(context.Background(), "Tom"); err != nil || view.String() != "db:Tom" || loads != 1 {
+		t.Fatalf("expected reload after Remove, got %s, %d loads", view, loads)
+	}
+}
+
+func TestSetRemovePeers(t *testing.T) {
+	group := NewGroup("set-peers", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return nil, fmt.Errorf("%s should be loaded from peer", key)
+		}), WithHotCache(1<<10, 1))
+	owner, other := &fakePeer{}, &fakePeer{}
+	group.RegisterPeers(fakePicker{peer: owner, others: []*fakePeer{other}})
+
+	// 缓存一份hotCache副本。
+	if _, err := group.Get(context.Background(), "Tom"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 写入由负责的节点完成，其他节点删除副本。
+	if err := group.Set(context.Background(), "Tom", []byte("700")); err != nil {
+		t.Fatal(err)
+	}
+	if owner.sets["Tom"] != "700" || len(owner.removes) != 0 {
+		t.Fatalf("Set should be forwarded to owner, sets %v, removes %v", owner.sets, owner.removes)
+	}
+	if !reflect.DeepEqual(other.removes, []string{"Tom"}) {
+		t.Fatalf("Set should remove copies on other peers, removes %v", other.removes)
+	}
+	if hot := group.CacheStats(HotCache); hot.Items != 0 {
+		t.Fatalf("Set should remove local hot copy, stats %+v", hot)
+	}
+
+	if err := group.Remove(context.Background(), "Tom"); err != nil {
+		t.Fatal(err)
+	}
+	if len(owner.removes) != 1 || len(other.removes) != 2 {
+		t.Fatalf("Remove should be sent to every peer once, owner %v, other %v", owner.removes, other.removes)
+	}
+}
+
+func TestGetContext(t *testing.T) {
+	group := NewGroup("ctx", 2<<10, ContextGetterFunc(
+		func(ctx context.Context, key string) ([]byte, error) {
+			// 模拟一个很慢的数据库，只能等待ctx结束。
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}), WithLoadTimeout(50*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := group.Get(ctx, "Tom"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestGetContextShared(t *testing.T) {
+	var loads int32
+	release := make(chan struct{})
+	group := NewGroup("ctx-shared", 2<<10, ContextGetterFunc(
+		func(ctx context.Context, key string) ([]byte, error) {
+			atomic.AddInt32(&loads, 1)
+			select {
+			case <-release:
+				return []byte(db[key]), nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}))
+
+	// 发起加载的调用方被取消，不影响等待同一次加载的其他调用方。
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := group.Get(ctx, "Tom")
+		first <- err
+	}()
+	for atomic.LoadInt32(&loads) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	second := make(chan error, 1)
+	go func() {
+		v, err := group.Get(context.Background(), "Tom")
+		if err == nil && v.String() != "630" {
+			err = fmt.Errorf("unexpected value %s", v)
+		}
+		second <- err
+	}()
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected Canceled, got %v", err)
+	}
+	close(release)
+	if err := <-second; err != nil {
+		t.Fatalf("waiter should get the shared value, got %v", err)
+	}
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Fatalf("expected 1 load, got %d", n)
+	}
+}
+
+func TestStats(t *testing.T) {
+	group := NewGroup("stats", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			if v, ok := db[key]; ok {
+				return []byte(v), nil
+			}
+			return nil, fmt.Errorf("%s not exist", key)
+		}))
+
+	ctx := context.Background()
+	group.Get(ctx, "Tom")
+	group.Get(ctx, "Tom")
+	group.Get(ctx, "unknown")
+
+	stats := group.Stats()
+	if stats.Gets.Get() != 3 || stats.CacheHits.Get() != 1 || stats.Loads.Get() != 2 ||
+		stats.LocalLoads.Get() != 1 || stats.LocalLoadErrs.Get() != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if stats.MainCache.Items != 1 || stats.MainCache.Bytes != entrySize("Tom", ByteView{b: []byte(db["Tom"])}) {
+		t.Fatalf("unexpected main cache stats %+v", stats.MainCache)
+	}
+}
+
+func TestNegativeCache(t *testing.T) {
+	loads := make(map[string]int)
+	group := NewGroup("negative", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads[key]++
+			if key == "flaky" {
+				// 临时错误，不应该被缓存。
+				return nil, fmt.Errorf("database timeout")
+			}
+			return nil, fmt.Errorf("%s not exist: %w", key, ErrNotFound)
+		}), WithNegativeTTL(time.Minute))
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := group.Get(ctx, "unknown"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+		if _, err := group.Get(ctx, "flaky"); err == nil || errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected transient error, got %v", err)
+		}
+	}
+	if loads["unknown"] != 1 || loads["flaky"] != 3 {
+		t.Fatalf("unexpected loads %v", loads)
+	}
+	if stats := group.Stats(); stats.NegativeHits.Get() != 2 {
+		t.Fatalf("expected 2 negative hits, got %d", stats.NegativeHits.Get())
+	}
+
+	// 写入后负缓存失效。
+	group.Set(ctx, "unknown", []byte("630"))
+	if view, err := group.Get(ctx, "unknown"); err != nil || view.String() != "630" {
+		t.Fatalf("expected value written by Set, got %s, %v", view, err)
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	loads := 0
+	group := NewGroup("bloom", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			if v, ok := db[key]; ok {
+				return []byte(v), nil
+			}
+			return nil, fmt.Errorf("%s not exist: %w", key, ErrNotFound)
+		}), WithBloomFilter(100, 0.01, func(ctx context.Context) ([]string, error) {
+		keys := make([]string, 0, len(db))
+		for k := range db {
+			keys = append(keys, k)
+		}
+		return keys, nil
+	}))
+
+	ctx := context.Background()
+	if err := group.LoadBloomFilter(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if view, err := group.Get(ctx, "Tom"); err != nil || view.String() != "630" {
+		t.Fatalf("failed to get Tom: %v", err)
+	}
+	// 不存在的key被布隆过滤器拒绝，不会调用回调函数。
+	if _, err := group.Get(ctx, "random-key"); !errors.Is(err, ErrNotFound) || loads != 1 {
+		t.Fatalf("expected bloom filter reject, got %v with %d loads", err, loads)
+	}
+
+	// Set写入的key被加入布隆过滤器。
+	group.Set(ctx, "Kate", []byte("601"))
+	group.Remove(ctx, "Kate")
+	if _, err := group.Get(ctx, "Kate"); !errors.Is(err, ErrNotFound) || loads != 2 {
+		t.Fatalf("expected key written by Set to pass bloom filter, got %v with %d loads", err, loads)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		loads int
+		fail  bool
+		gate  chan struct{} // 不为nil时，加载阻塞到gate被关闭。
+	)
+	group := NewGroup("stale", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			mu.Lock()
+			wait := gate
+			mu.Unlock()
+			if wait != nil {
+				<-wait
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if fail {
+				return nil, fmt.Errorf("database down")
+			}
+			loads++
+			return []byte(strconv.Itoa(loads)), nil
+		}), WithTTL(20*time.Millisecond), WithStaleWhileRevalidate(100*time.Millisecond, 200*time.Millisecond))
+
+	ctx := context.Background()
+	if view, _ := group.Get(ctx, "k"); view.String() != "1" {
+		t.Fatalf("expected 1, got %s", view)
+	}
+	// Get返回的值不带stale和expire，从mainCache中读取。
+	cached := func() ByteView {
+		view, _ := group.mainCache.get("k")
+		return view
+	}
+
+	// 软过期后直接返回旧值，只启动一次后台刷新。刷新被gate阻塞，不会在断言之前完成。
+	release := make(chan struct{})
+	mu.Lock()
+	gate = release
+	mu.Unlock()
+	time.Sleep(time.Until(cached().stale))
+	for i := 0; i < 5; i++ {
+		if view, _ := group.Get(ctx, "k"); view.String() != "1" {
+			t.Fatalf("expected stale value 1, got %s", view)
+		}
+	}
+	mu.Lock()
+	gate = nil
+	mu.Unlock()
+	close(release)
+	waitRefreshed(t, group, "k")
+	if stats := group.Stats(); stats.StaleHits.Get() != 5 || stats.Refreshes.Get() != 1 {
+		t.Fatalf("expected 5 stale hits and 1 refresh, got %d, %d", stats.StaleHits.Get(), stats.Refreshes.Get())
+	}
+
+	// 之后的加载全部失败。
+	mu.Lock()
+	fail = true
+	mu.Unlock()
+	if view, _ := group.Get(ctx, "k"); view.String() != "2" {
+		t.Fatalf("expected refreshed value 2, got %s", view)
+	}
+	view := cached()
+
+	// 硬过期后加载失败，在最大过期时长内仍然返回旧值。
+	time.Sleep(time.Until(view.expire))
+	waitRefreshed(t, group, "k")
+	if view, err := group.Get(ctx, "k"); err != nil || view.String() != "2" {
+		t.Fatalf("expected stale value 2 after failed load, got %s, %v", view, err)
+	}
+
+	// 超过最大过期时长后返回错误。
+	time.Sleep(time.Until(view.stale.Add(200*time.Millisecond)) + time.Millisecond)
+	if _, err := group.Get(ctx, "k"); err == nil {
+		t.Fatal("expected error after max staleness")
+	}
+}
+
+// 等待key的后台刷新结束，刷新结束之前缓存已经更新。
+func waitRefreshed(t *testing.T, g *Group, key string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, busy := g.refreshing.Load(key); !busy {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("refresh of %s did not finish", key)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBloomFilterReload(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	group := NewGroup("bloom-reload", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			if key == "Kate" {
+				return []byte("601"), nil
+			}
+			return nil, fmt.Errorf("%s not exist: %w", key, ErrNotFound)
+		}), WithBloomFilter(100, 0.01, func(ctx context.Context) ([]string, error) {
+		started <- struct{}{}
+		<-release
+		return []string{"Tom"}, nil
+	}))
+
+	ctx := context.Background()
+	close(release)
+	if err := group.LoadBloomFilter(ctx); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	// 重建过滤器期间Set写入的key不在KeySource的快照中，仍然要加入新的过滤器。
+	release = make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- group.LoadBloomFilter(ctx) }()
+	<-started
+	if err := group.Set(ctx, "Kate", []byte("601")); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// key离开缓存之后不能被布隆过滤器拒绝。
+	group.mainCache.remove("Kate")
+	if v, err := group.Get(ctx, "Kate"); err != nil || v.String() != "601" {
+		t.Fatalf("key written during reload should pass bloom filter, got %v, %v", v, err)
+	}
+	if _, err := group.Get(ctx, "random-key"); !errors.Is(err, ErrNotFound) || group.stats.BloomRejects.Get() != 1 {
+		t.Fatalf("expected bloom filter reject, got %v", err)
+	}
+}
+
+// 实现了BatchGetter的Getter。
+type batchGetter struct {
+	batches [][]string
+}
+
+func (b *batchGetter) Get(ctx context.Context, key string) ([]byte, error) {
+	return nil, fmt.Errorf("%s should be loaded in batch", key)
+}
+
+func (b *batchGetter) GetMulti(_ context.Context, keys []string) (map[string][]byte, error) {
+	b.batches = append(b.batches, keys)
+	values := make(map[string][]byte)
+	for _, key := range keys {
+		if v, ok := db[key]; ok {
+			values[key] = []byte(v)
+		}
+	}
+	return values, nil
+}
+
+func TestGetMulti(t *testing.T) {
+	getter := &batchGetter{}
+	group := NewGroup("multi", 2<<10, getter, WithNegativeTTL(time.Minute))
+
+	ctx := context.Background()
+	values, err := group.GetMulti(ctx, []string{"Tom", "Jack", "unknown", "Tom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || values["Tom"].String() != "630" || values["Jack"].String() != "589" {
+		t.Fatalf("unexpected values %v", values)
+	}
+	// 重复的key只加载一次，所有未命中的key只调用一次GetMulti。
+	if !reflect.DeepEqual(getter.batches, [][]string{{"Tom", "Jack", "unknown"}}) {
+		t.Fatalf("unexpected batches %v", getter.batches)
+	}
+
+	// 命中缓存和负缓存，不需要再加载。
+	if values, err = group.GetMulti(ctx, []string{"Tom", "Sam", "unknown"}); err != nil || len(values) != 2 {
+		t.Fatalf("unexpected values %v, %v", values, err)
+	}
+	if !reflect.DeepEqual(getter.batches[1:], [][]string{{"Sam"}}) {
+		t.Fatalf("unexpected batches %v", getter.batches)
+	}
+}
+
+// Get和GetMulti阻塞在gate上，记录每次加载的key。
+type blockingBatchGetter struct {
+	gate    chan struct{}
+	started chan string
+	mu      sync.Mutex
+	loaded  []string
+}
+
+func (b *blockingBatchGetter) Get(_ context.Context, key string) ([]byte, error) {
+	b.mu.Lock()
+	b.loaded = append(b.loaded, key)
+	b.mu.Unlock()
+	b.started <- key
+	<-b.gate
+	return []byte(db[key]), nil
+}
+
+func (b *blockingBatchGetter) GetMulti(_ context.Context, keys []string) (map[string][]byte, error) {
+	b.mu.Lock()
+	b.loaded = append(b.loaded, keys...)
+	b.mu.Unlock()
+	b.started <- strings.Join(keys, ",")
+	<-b.gate
+	values := make(map[string][]byte)
+	for _, key := range keys {
+		values[key] = []byte(db[key])
+	}
+	return values, nil
+}
+
+func TestGetMultiDedup(t *testing.T) {
+	getter := &blockingBatchGetter{gate: make(chan struct{}), started: make(chan string, 4)}
+	group := NewGroup("multi-dedup", 2<<10, getter)
+	ctx := context.Background()
+
+	// Get正在加载Tom时，GetMulti只批量加载Jack，Tom等待Get的结果。
+	getDone := make(chan error, 1)
+	go func() {
+		v, err := group.Get(ctx, "Tom")
+		if err == nil && v.String() != "630" {
+			err = fmt.Errorf("unexpected value %v", v)
+		}
+		getDone <- err
+	}()
+	if key := <-getter.started; key != "Tom" {
+		t.Fatalf("unexpected load %q", key)
+	}
+	multiDone := make(chan map[string]ByteView, 1)
+	go func() {
+		values, _ := group.GetMulti(ctx, []string{"Tom", "Jack"})
+		multiDone <- values
+	}()
+	if keys := <-getter.started; keys != "Jack" {
+		t.Fatalf("Tom is loading, expected batch Jack, got %q", keys)
+	}
+
+	// 批量加载Jack期间的Get等待批量加载的结果。
+	jackDone := make(chan error, 1)
+	go func() {
+		v, err := group.Get(ctx, "Jack")
+		if err == nil && v.String() != "589" {
+			err = fmt.Errorf("unexpected value %v", v)
+		}
+		jackDone <- err
+	}()
+	for group.stats.Loads.Get() < 4 {
+		time.Sleep(time.Millisecond)
+	}
+	close(getter.gate)
+
+	if err := <-getDone; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-jackDone; err != nil {
+		t.Fatal(err)
+	}
+	values := <-multiDone
+	if len(values) != 2 || values["Tom"].String() != "630" || values["Jack"].String() != "589" {
+		t.Fatalf("unexpected values %v", values)
+	}
+	if !reflect.DeepEqual(getter.loaded, []string{"Tom", "Jack"}) {
+		t.Fatalf("each key should be loaded once, got %v", getter.loaded)
+	}
+}
+
+func TestGetMultiPeers(t *testing.T) {
+	group := NewGroup("multi-peers", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return nil, fmt.Errorf("%s should be loaded from peer", key)
+		}))
+	peer := &fakePeer{}
+	group.RegisterPeers(fakePicker{peer: peer})
+
+	values, err := group.GetMulti(context.Background(), []string{"Tom", "Jack", "unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || values["Tom"].String() != "peer:Tom" || values["Jack"].String() != "peer:Jack" {
+		t.Fatalf("unexpected values %v", values)
+	}
+	// 同一个节点负责的key只发送一次批量请求。
+	if peer.gets != 0 || !reflect.DeepEqual(peer.multis, [][]string{{"Tom", "Jack", "unknown"}}) {
+		t.Fatalf("expected one batched request, got %d gets and %v", peer.gets, peer.multis)
+	}
+}
+
+func TestGroupAdmin(t *testing.T) {
+	group := NewGroup("admin", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(db[key]), nil
+		}), WithShards(1))
+
+	ctx := context.Background()
+	for _, key := range []string{"Tom", "Jack", "Sam"} {
+		group.Get(ctx, key)
+	}
+	if view, ok := group.Peek("Tom"); !ok || view.String() != "630" || group.Contains("Kate") {
+		t.Fatalf("unexpected peek result %s, %v", view, ok)
+	}
+	if keys := group.Keys(MainCache); !reflect.DeepEqual(keys, []string{"Sam", "Jack", "Tom"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+
+	values := make(map[string]string)
+	group.Range(MainCache, func(key string, value ByteView) bool {
+		values[key] = value.String()
+		return true
+	})
+	if len(values) != 3 || values["Jack"] != "589" {
+		t.Fatalf("unexpected values %v", values)
+	}
+
+	// 缩小容量后只剩下最近访问的记录。
+	group.Resize(MainCache, entrySize("Sam", ByteView{b: []byte(db["Sam"])}))
+	if keys := group.Keys(MainCache); !reflect.DeepEqual(keys, []string{"Sam"}) {
+		t.Fatalf("unexpected keys after resize %v", keys)
+	}
+	group.Clear(MainCache)
+	if stats := group.CacheStats(MainCache); stats.Items != 0 || stats.Bytes != 0 {
+		t.Fatalf("expected empty cache, stats %+v", stats)
+	}
+}
+
+func TestEvictionListener(t *testing.T) {
+	type event struct {
+		which  CacheType
+		key    string
+		value  string
+		reason EvictReason
+	}
+	var events []event
+	group := NewGroup("evict", entrySize("Jack", ByteView{b: []byte(db["Jack"])}), GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(db[key]), nil
+		}), WithShards(1), WithEvictionListener(func(which CacheType, key string, value ByteView, reason EvictReason) {
+		events = append(events, event{which, key, value.String(), reason})
+	}))
+
+	ctx := context.Background()
+	group.Get(ctx, "Tom")
+	group.Set(ctx, "Tom", []byte("700"))
+	group.Get(ctx, "Jack")
+	group.Remove(ctx, "Jack")
+
+	expect := []event{
+		{MainCache, "Tom", "630", EvictReplaced},
+		{MainCache, "Tom", "700", EvictCapacity},
+		{MainCache, "Jack", "589", EvictRemoved},
+	}
+	if !reflect.DeepEqual(events, expect) {
+		t.Fatalf("unexpected events %v", events)
+	}
+}
+
+func TestMaxEntries(t *testing.T) {
+	group := NewGroup("max-entries", 64<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(db[key]), nil
+		}), WithShards(1), WithMaxEntries(2))
+
+	ctx := context.Background()
+	for _, key := range []string{"Tom", "Jack", "Sam"} {
+		group.Get(ctx, key)
+	}
+	if keys := group.Keys(MainCache); !reflect.DeepEqual(keys, []string{"Sam", "Jack"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+}
+
+func TestMaxEntriesShards(t *testing.T) {
+	// 使用默认的分片数量时，最大缓存项数量同样限制整个mainCache。
+	group := NewGroup("max-entries-shards", 1<<20, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}), WithMaxEntries(10))
+
+	ctx := context.Background()
+	for i := 0; i < 1000; i++ {
+		group.Get(ctx, fmt.Sprintf("key%d", i))
+	}
+	if n := group.CacheStats(MainCache).Items; n != 10 {
+		t.Fatalf("expected 10 items, got %d", n)
+	}
+}
+
+func TestSlabStorage(t *testing.T) {
+	loads := 0
+	group := NewGroup("slab", 64<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(db[key]), nil
+		}), WithSlabStorage(0), WithTTL(time.Hour))
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if v, err := group.Get(ctx, "Tom"); err != nil || v.String() != "630" {
+			t.Fatalf("unexpected value %v, %v", v, err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("expected 1 load, got %d", loads)
+	}
+	if err := group.Set(ctx, "Tom", []byte("631")); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := group.Peek("Tom"); !ok || v.String() != "631" {
+		t.Fatalf("unexpected value %v", v)
+	}
+	if _, ok := group.mainCache.shard("Tom").store.(*slabStore); !ok {
+		t.Fatal("mainCache should use slab storage")
+	}
+}
+
+func TestPeerFallback(t *testing.T) {
+	down := errors.New("connection refused")
+	tests := []struct {
+		name      string
+		fallback  PeerFallback
+		attempts  int
+		self      bool // 后继节点是否是本节点。
+		value     string
+		loads     int
+		ownerGets int
+		nextGets  int
+	}{
+		{name: "local", fallback: FallbackLocal, value: "630", loads: 1, ownerGets: 1},
+		{name: "fail", fallback: FallbackFail, ownerGets: 1},
+		{name: "retry", fallback: FallbackRetryOwner, attempts: 2, ownerGets: 3},
+		{name: "next", fallback: FallbackNextPeer, value: "peer:Tom", ownerGets: 1, nextGets: 1},
+		{name: "next-self", fallback: FallbackNextPeer, self: true, value: "630", loads: 1, ownerGets: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loads := 0
+			group := NewGroup("fallback-"+tt.name, 64<<10, GetterFunc(
+				func(key string) ([]byte, error) {
+					loads++
+					return []byte(db[key]), nil
+				}), WithPeerFallback(tt.fallback, tt.attempts))
+			owner, next := &fakePeer{err: down}, &fakePeer{}
+			replicas := []PeerGetter{owner, next}
+			if tt.self {
+				replicas[1] = nil
+			}
+			group.RegisterPeers(fakeReplicaPicker{fakePicker{peer: owner}, replicas})
+
+			v, err := group.Get(context.Background(), "Tom")
+			if tt.value == "" {
+				if !errors.Is(err, down) {
+					t.Fatalf("expected %v, got %v, %v", down, v, err)
+				}
+			} else if err != nil || v.String() != tt.value {
+				t.Fatalf("expected %s, got %v, %v", tt.value, v, err)
+			}
+			if loads != tt.loads || owner.gets != tt.ownerGets || next.gets != tt.nextGets {
+				t.Fatalf("unexpected calls: %d loads, %d owner gets, %d next gets", loads, owner.gets, next.gets)
+			}
+		})
+	}
+}
+
+func TestPeerFallbackMulti(t *testing.T) {
+	loads := 0
+	group := NewGroup("fallback-multi", 64<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(db[key]), nil
+		}), WithPeerFallback(FallbackNextPeer, 1))
+	owner, next := &fakePeer{err: errors.New("connection refused")}, &fakePeer{}
+	group.RegisterPeers(fakeReplicaPicker{fakePicker{peer: owner}, []PeerGetter{owner, next}})
+
+	values, err := group.GetMulti(context.Background(), []string{"Tom", "Jack"})
+	if err != nil || values["Tom"].String() != "peer:Tom" || values["Jack"].String() != "peer:Jack" {
+		t.Fatalf("unexpected values %v, %v", values, err)
+	}
+	if loads != 0 || len(owner.multis) != 1 || next.gets != 2 {
+		t.Fatalf("unexpected calls: %d loads, %v owner multis, %d next gets", loads, owner.multis, next.gets)
+	}
+}
+
+func TestPeerRequest(t *testing.T) {
+	loads := 0
+	group := NewGroup("peer-request", 64<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(db[key]), nil
+		}), WithPeerFallback(FallbackFail, 0))
+	owner := &fakePeer{}
+	group.RegisterPeers(fakePicker{peer: owner})
+
+	// 其他节点转发来的请求只在本节点加载，即使本节点认为key由其他节点负责。
+	ctx := NewPeerContext(context.Background())
+	if v, err := group.Get(ctx, "Tom"); err != nil || v.String() != "630" {
+		t.Fatalf("unexpected value %v, %v", v, err)
+	}
+	if values, err := group.GetMulti(ctx, []string{"Jack"}); err != nil || values["Jack"].String() != "589" {
+		t.Fatalf("unexpected values %v, %v", values, err)
+	}
+	if loads != 2 || owner.gets != 0 || len(owner.multis) != 0 {
+		t.Fatalf("unexpected calls: %d loads, %d owner gets, %v owner multis", loads, owner.gets, owner.multis)
+	}
+}
+
+func TestPeerPolicy(t *testing.T) {
+	loads := 0
+	down := errors.New("connection refused")
+	group := NewGroup("peer-policy", 64<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(db[key]), nil
+		}), WithPeerPolicy(PeerPolicy{Fallback: FallbackFail, Retries: 3, Backoff: 10 * time.Millisecond, MaxBackoff: 15 * time.Millisecond}))
+	owner := &fakePeer{err: down, failures: 2}
+	group.RegisterPeers(fakePicker{peer: owner})
+
+	// 前两次失败，第二次重试成功，两次重试之前分别等待10ms和15ms。
+	start := time.Now()
+	v, err := group.Get(context.Background(), "Tom")
+	if err != nil || v.String() != "peer:Tom" {
+		t.Fatalf("unexpected value %v, %v", v, err)
+	}
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+		t.Fatalf("expected backoff of at least 25ms, got %v", elapsed)
+	}
+	if owner.gets != 3 || loads != 0 {
+		t.Fatalf("unexpected calls: %d owner gets, %d loads", owner.gets, loads)
+	}
+
+	// 重试用完之后返回最后一次的PeerError。
+	owner.gets, owner.failures = 0, 0
+	_, err = group.Get(context.Background(), "Jack")
+	var peerErr *PeerError
+	if !errors.As(err, &peerErr) || peerErr.Peer != "*gocache.fakePeer" || peerErr.Key != "Jack" || !errors.Is(err, down) {
+		t.Fatalf("expected PeerError, got %v", err)
+	}
+	if owner.gets != 4 || loads != 0 {
+		t.Fatalf("unexpected calls: %d owner gets, %d loads", owner.gets, loads)
+	}
+
+	// 调用方放弃等待时立即返回，不等待重试结束。重试在共享的加载中继续，结果留给其他等待的调用方。
+	owner.gets = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if _, err = group.Get(ctx, "Sam"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 40*time.Millisecond {
+		// 三次重试之前的退避共40ms。
+		t.Fatalf("caller should not wait for retries, took %v", elapsed)
+	}
+}
+
+func TestPeerPolicyBackoff(t *testing.T) {
+	p := PeerPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	for i, want := range []time.Duration{10, 20, 40, 50, 50} {
+		if d := p.backoff(i); d != want*time.Millisecond {
+			t.Fatalf("backoff(%d) = %v, want %v", i, d, want*time.Millisecond)
+		}
+	}
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.backoff(1); d < 10*time.Millisecond || d > 20*time.Millisecond {
+			t.Fatalf("backoff with jitter %v out of range", d)
+		}
+	}
+	if d := (PeerPolicy{}).backoff(3); d != 0 {
+		t.Fatalf("expected no backoff, got %v", d)
+	}
+}
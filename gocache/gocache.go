@@ -1,11 +1,18 @@
 package gocache
 
 import (
+	"GoCache/gocache/bloom"
+	"GoCache/gocache/policy"
 	"GoCache/gocache/singleflight"
 	pb "GoCache/gocachepb"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Group 一个Group可以认为是一个缓存的命名空间，每个Group拥有一个唯一的名称name。
@@ -13,11 +20,54 @@ import (
 type Group struct {
 	name      string
 	getter    Getter // 缓存未命中时获取源数据的回调。
-	mainCache cache  // 并发缓存。
-	peers     PeerPicker
+	mainCache *cache // 并发缓存。
+	// 从远程节点获取的热点数据的缓存，容量比mainCache小。
+	// 本节点访问远程节点负责的热点key时，可以直接命中，省去一次网络请求。
+	hotCache *cache
+	peers    PeerPicker
 	// use singleFlight.Group to make sure that
 	// each key is only fetched once
-	loader    *singleflight.Group
+	loader *singleflight.Group
+	// singleflight中共享的加载的超时时间，<= 0代表不限制。
+	loadTimeout time.Duration
+	ttl         time.Duration // 缓存项的默认过期时间，0代表永不过期。
+	shards      int           // mainCache的分片数量，0代表使用默认值。
+	// mainCache的最大缓存项数量，0代表不限制。
+	maxEntries int
+	// mainCache使用的淘汰策略的构造函数，为nil时使用LRU。
+	newPolicy func() policy.Policy[string]
+	// 大于0时mainCache和hotCache使用slab存储，值是每块slab的大小。
+	slabSize int64
+	// hotCache的最大缓存容量。
+	hotCacheBytes int64
+	// 从远程节点获取的值，有1/hotSampleRate的概率被添加到hotCache中，<= 0代表不使用hotCache。
+	hotSampleRate int
+	// 负缓存，记录数据不存在的key，避免缓存穿透。
+	negCache *cache
+	// 负缓存的过期时间，<= 0代表不开启负缓存。
+	negativeTTL time.Duration
+	// 布隆过滤器，缓存未命中时过滤掉一定不存在的key。为nil代表不开启或者还没有加载。
+	bloom       atomic.Pointer[bloom.Filter]
+	bloomConfig *bloomConfig
+	// 软过期之后，仍然直接返回旧值并在后台刷新的时长，<= 0代表不开启stale-while-revalidate。
+	staleTTL time.Duration
+	// 软过期之后，刷新失败时最多继续返回旧值的时长。
+	maxStale time.Duration
+	// 正在后台刷新的key，保证每个key同时只有一个刷新协程。
+	refreshing sync.Map
+	// 从负责key的远程节点获取失败时的重试和回退策略。
+	peerPolicy PeerPolicy
+	// 共享内存池，为nil代表不使用。
+	poolConfig *poolConfig
+	// 根据内存压力调整缓存容量，为nil代表不使用。
+	capacity *CapacityController
+	// 缓存项被移除时的回调函数，写时复制。
+	listeners   atomic.Pointer[[]EvictionListener]
+	listenersMu sync.Mutex
+	// 统计数据。
+	stats Stats
+	// 延迟直方图，用于MetricsHandler。
+	metrics *groupMetrics
 }
 
 /*
@@ -25,29 +75,57 @@ type Group struct {
 这是Go语言将其他函数（参数返回值定义和F一致）转换为接口A的常用技巧。
 */
 
-// Getter 定义接口Getter和回调函数 Get(key string) ([]byte, error)，参数是key，返回值是[]byte。
+// Getter 定义接口Getter和回调函数 Get(ctx context.Context, key string) ([]byte, error)，参数是key，返回值是[]byte。
+// ctx携带调用方的截止时间和取消信号，实现应当在ctx结束时尽快返回。
 type Getter interface {
-	Get(key string) ([]byte, error)
+	Get(ctx context.Context, key string) ([]byte, error)
 }
 
 // GetterFunc 定义函数类型GetterFunc，并实现Getter接口的Get方法。
 // 函数类型实现某一个接口，称之为接口型函数，方便使用者在调用时既能够传入函数作为参数，也能够传入实现了该接口的结构体作为参数。
 // 关键字 type 类型名 GetterFunc 类型 func(key string) ([]byte, error)函数。
+// GetterFunc不关心ctx，是为了兼容不需要ctx的回调函数而保留的适配器，需要ctx时使用ContextGetterFunc。
 type GetterFunc func(key string) ([]byte, error)
 
 // Get GetterFunc类型的成员方法。
-// 传入参数是string类型的key，返回值是用GetterFunc中的方法来处理key并返回字节数组。
-func (f GetterFunc) Get(key string) ([]byte, error) {
+// 传入参数是string类型的key，返回值是用GetterFunc中的方法来处理key并返回字节数组。ctx会被忽略。
+func (f GetterFunc) Get(_ context.Context, key string) ([]byte, error) {
 	return f(key)
 }
 
+// ContextGetterFunc 与GetterFunc类似，回调函数可以使用ctx，例如将截止时间传递给数据库查询。
+type ContextGetterFunc func(ctx context.Context, key string) ([]byte, error)
+
+// Get ContextGetterFunc类型的成员方法。
+func (f ContextGetterFunc) Get(ctx context.Context, key string) ([]byte, error) {
+	return f(ctx, key)
+}
+
+// ErrNotFound 代表key对应的数据不存在。Getter在数据不存在时应当返回ErrNotFound（或者包装了ErrNotFound的错误），
+// 以便和数据库超时等临时错误区分开。开启WithNegativeTTL后，数据不存在的结果会被缓存一段时间。
+var ErrNotFound = errors.New("gocache: key not found")
+
+const (
+	// hotCache的默认容量是mainCache的1/defaultHotCacheRatio。
+	defaultHotCacheRatio = 8
+	// negCache的默认容量是mainCache的1/defaultNegCacheRatio。
+	defaultNegCacheRatio = 16
+	// 默认对1/defaultHotSampleRate从远程节点获取的值进行缓存。
+	defaultHotSampleRate = 10
+	// singleflight中共享的加载的默认超时时间。
+	defaultLoadTimeout = 30 * time.Second
+	// hotCache和negCache默认容量的下限。每个缓存项都有entryOverhead的额外开销，
+	// mainCache较小时按比例计算的容量可能连一个缓存项都放不下。
+	minDerivedCacheBytes = 8 << 10
+)
+
 var (
 	mu     sync.RWMutex              // 读写锁。
 	groups = make(map[string]*Group) // 全部的缓存字典。
 )
 
-// NewGroup 实例化Group并将group存储在全局变量groups中。
-func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
+// NewGroup 实例化Group并将group存储在全局变量groups中。可以通过opts配置可选参数，例如WithTTL。
+func NewGroup(name string, cacheBytes int64, getter Getter, opts ...GroupOption) *Group {
 	if getter == nil {
 		// 如果没有传入回调函数。
 		panic("nil Getter")
@@ -55,18 +133,63 @@ func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
 	mu.Lock()
 	defer mu.Unlock()
 	g := &Group{
-		name:   name,
-		getter: getter,
-		mainCache: cache{
-			// lru中的最大缓存容量。
-			cacheBytes: cacheBytes,
-		},
-		loader: &singleflight.Group{},
+		name:          name,
+		getter:        getter,
+		loader:        &singleflight.Group{},
+		loadTimeout:   defaultLoadTimeout,
+		hotCacheBytes: derivedCacheBytes(cacheBytes, defaultHotCacheRatio),
+		hotSampleRate: defaultHotSampleRate,
+		metrics:       newGroupMetrics(),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	// lru中的最大缓存容量，由各个分片平分。
+	shards := g.shards
+	if shards <= 0 && g.maxEntries > 0 {
+		// 自动选择分片数量时，分片数量不超过最大缓存项数量，使每个分片至少分到1个缓存项。
+		shards = autoShards(cacheBytes)
+		if shards > g.maxEntries {
+			shards = g.maxEntries
+		}
+	}
+	g.mainCache = newCache(cacheBytes, shards, g.newPolicy)
+	// 只受内存池限制的Group，内存池不统计hotCache和negCache，按照内存池的上限计算它们的默认容量，避免不受任何限制。
+	derivedBase := cacheBytes
+	if derivedBase <= 0 && g.poolConfig != nil {
+		derivedBase = g.poolConfig.pool.Limit()
+		if g.hotCacheBytes == 0 {
+			g.hotCacheBytes = derivedCacheBytes(derivedBase, defaultHotCacheRatio)
+		}
+	}
+	g.hotCache = newCache(g.hotCacheBytes, 0, nil)
+	g.negCache = newCache(derivedCacheBytes(derivedBase, defaultNegCacheRatio), 0, nil)
+	g.mainCache.setMaxEntries(g.maxEntries)
+	g.mainCache.setSlabSize(g.slabSize)
+	g.hotCache.setSlabSize(g.slabSize)
+	g.mainCache.setOnEvict(g.notifyEvicted(MainCache))
+	if g.poolConfig != nil {
+		g.poolConfig.pool.register(g, g.poolConfig.weight, g.poolConfig.reserve)
+	}
+	g.hotCache.setOnEvict(g.notifyEvicted(HotCache))
+	if g.capacity != nil {
+		g.capacity.addGroup(g, cacheBytes, g.hotCacheBytes)
 	}
 	groups[name] = g
 	return g
 }
 
+// 返回hotCache或negCache的默认容量：mainCache的1/ratio，但不小于minDerivedCacheBytes。
+func derivedCacheBytes(cacheBytes, ratio int64) int64 {
+	if cacheBytes <= 0 {
+		return 0
+	}
+	if n := cacheBytes / ratio; n > minDerivedCacheBytes {
+		return n
+	}
+	return minDerivedCacheBytes
+}
+
 // GetGroup 用来返回特定名称的Group，使用了只读锁RLock()，因为不涉及到任何冲突变量的写操作。
 func GetGroup(name string) *Group {
 	mu.RLock()
@@ -75,21 +198,274 @@ func GetGroup(name string) *Group {
 	return g
 }
 
-// Get 用来返回key对应的value。
-func (g *Group) Get(key string) (ByteView, error) {
+// Get 用来返回key对应的value。ctx结束时，正在等待的加载会提前返回ctx.Err()。
+func (g *Group) Get(ctx context.Context, key string) (ByteView, error) {
 	if key == "" {
 		// 传入的key为空。
 		return ByteView{}, fmt.Errorf("key is required")
 	}
 
-	if v, ok := g.mainCache.get(key); ok {
-		// 命中缓存。
-		log.Println("[GoCache] hit")
+	g.stats.Gets.Add(1)
+	v, fresh, ok := g.lookup(key)
+	if ok && fresh {
 		return v, nil
 	}
+	if !ok && g.knownMissing(key) {
+		return ByteView{}, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
 
 	// 使用用户传递的回调函数，来加载缓存。
-	return g.load(key)
+	value, err := g.load(ctx, key)
+	if err != nil && ok && g.useStale(ctx, key, err) {
+		return v, nil
+	}
+	return value, err
+}
+
+// 在缓存中查找key。命中未过期的缓存值时fresh为true；命中软过期的缓存值时，硬过期之前直接返回旧值（fresh为true），
+// 并在后台刷新；硬过期之后fresh为false，需要等待加载，加载失败时才返回旧值。
+func (g *Group) lookup(key string) (value ByteView, fresh, ok bool) {
+	if value, ok = g.lookupCache(key); !ok {
+		return
+	}
+	if now := time.Now(); value.stale.IsZero() || now.Before(value.stale) {
+		// 命中缓存。
+		g.stats.CacheHits.Add(1)
+		log.Println("[GoCache] hit")
+		return value, true, true
+	} else if now.Before(value.expire) {
+		g.stats.CacheHits.Add(1)
+		g.stats.StaleHits.Add(1)
+		g.refresh(key)
+		return value, true, true
+	}
+	return value, false, true
+}
+
+// 判断是否不需要加载就能确认key对应的数据不存在（命中负缓存或者被布隆过滤器拒绝）。
+func (g *Group) knownMissing(key string) bool {
+	if g.negativeTTL > 0 {
+		if _, ok := g.negCache.get(key); ok {
+			// 命中负缓存，数据不存在，不需要再访问数据库。
+			g.stats.NegativeHits.Add(1)
+			return true
+		}
+	}
+	if f := g.bloom.Load(); f != nil && !f.Test(key) {
+		// 布隆过滤器确认key一定不存在，不需要加载。
+		g.stats.BloomRejects.Add(1)
+		return true
+	}
+	return false
+}
+
+// 硬过期的旧值加载失败时，判断是否返回旧值（lru中的记录在超过最大过期时长后才会被删除）。
+// 数据源暂时不可用时返回旧值；数据已经不存在时删除旧值，调用方放弃等待时返回错误。
+func (g *Group) useStale(ctx context.Context, key string, err error) bool {
+	if errors.Is(err, ErrNotFound) {
+		g.mainCache.remove(key)
+		return false
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	log.Println("[GoCache] serving stale value after failed load", err)
+	g.stats.StaleHits.Add(1)
+	return true
+}
+
+// 在后台刷新key，同一个key同时只有一个刷新协程。刷新和前台的加载一样经过singleflight去重。
+func (g *Group) refresh(key string) {
+	if _, loaded := g.refreshing.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+	g.stats.Refreshes.Add(1)
+	go func() {
+		defer g.refreshing.Delete(key)
+		ctx, cancel := context.WithTimeout(context.Background(), g.staleTTL)
+		defer cancel()
+		if _, err := g.load(ctx, key); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				// 数据已经被删除，旧值也不再返回。
+				g.mainCache.remove(key)
+			}
+			log.Println("[GoCache] Failed to refresh", key, err)
+		}
+	}()
+}
+
+// 依次在mainCache和hotCache中查找key。
+func (g *Group) lookupCache(key string) (value ByteView, ok bool) {
+	if value, ok = g.mainCache.get(key); ok {
+		return
+	}
+	if g.hotSampleRate > 0 {
+		value, ok = g.hotCache.get(key)
+	}
+	return
+}
+
+// CacheStats 返回指定缓存的统计数据。
+func (g *Group) CacheStats(which CacheType) CacheStats {
+	if c := g.cacheOf(which); c != nil {
+		return c.stats()
+	}
+	return CacheStats{}
+}
+
+// 返回指定类型的缓存，不存在时返回nil。
+func (g *Group) cacheOf(which CacheType) *cache {
+	switch which {
+	case MainCache:
+		return g.mainCache
+	case HotCache:
+		return g.hotCache
+	default:
+		return nil
+	}
+}
+
+// Peek 依次在mainCache和hotCache中查找key，不会加载数据，也不影响淘汰顺序和统计数据。用于调试和管理。
+func (g *Group) Peek(key string) (ByteView, bool) {
+	if v, ok := g.mainCache.peek(key); ok {
+		return v, true
+	}
+	return g.hotCache.peek(key)
+}
+
+// Contains 判断key是否在本节点的mainCache或者hotCache中，语义与Peek相同。
+func (g *Group) Contains(key string) bool {
+	_, ok := g.Peek(key)
+	return ok
+}
+
+// Keys 返回指定缓存中所有的key。每个分片内越晚被淘汰的key越靠前。
+func (g *Group) Keys(which CacheType) []string {
+	if c := g.cacheOf(which); c != nil {
+		return c.keys()
+	}
+	return nil
+}
+
+// Range 遍历指定缓存中所有的记录，fn返回false时停止遍历。fn中不能访问该Group。
+func (g *Group) Range(which CacheType, fn func(key string, value ByteView) bool) {
+	if c := g.cacheOf(which); c != nil {
+		c.rangeAll(fn)
+	}
+}
+
+// Resize 修改指定缓存的容量，超出新容量的记录会被淘汰。cacheBytes <= 0代表不限制；
+// 开启WithSlabStorage时已经创建的slab无法不限制容量，保持原来的容量。
+func (g *Group) Resize(which CacheType, cacheBytes int64) {
+	if c := g.cacheOf(which); c != nil {
+		c.resize(cacheBytes)
+	}
+}
+
+// Clear 删除本节点指定缓存中所有的记录，不通知其他节点。
+func (g *Group) Clear(which CacheType) {
+	if c := g.cacheOf(which); c != nil {
+		c.clear()
+	}
+}
+
+// Set 写入key对应的value，例如数据库中的数据更新后主动刷新缓存。
+// 如果key由远程节点负责，则转发给该节点写入。同时删除其他节点（包括本节点）上的旧副本，例如hotCache中的副本。
+func (g *Group) Set(ctx context.Context, key string, value []byte) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	if g.peers != nil {
+		if peer, ok := g.peers.PickPeer(key); ok {
+			req := &pb.SetRequest{
+				Group: g.name,
+				Key:   key,
+				Value: value,
+			}
+			if err := peer.Set(ctx, req, &pb.Response{}); err != nil {
+				return err
+			}
+			g.removeLocally(key)
+			return g.broadcastRemove(ctx, key, peer)
+		}
+	}
+	g.setLocally(key, value)
+	return g.broadcastRemove(ctx, key, nil)
+}
+
+// Remove 删除key对应的缓存值，例如数据库中的数据被删除后主动失效缓存。
+// 如果key由远程节点负责，则转发给该节点删除。同时删除其他节点（包括本节点）上的副本。
+func (g *Group) Remove(ctx context.Context, key string) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	var owner PeerGetter
+	if g.peers != nil {
+		if peer, ok := g.peers.PickPeer(key); ok {
+			req := &pb.Request{
+				Group: g.name,
+				Key:   key,
+			}
+			if err := peer.Remove(ctx, req, &pb.Response{}); err != nil {
+				return err
+			}
+			owner = peer
+		}
+	}
+	g.removeLocally(key)
+	return g.broadcastRemove(ctx, key, owner)
+}
+
+// 只在本节点写入，不转发。hotCache中的旧副本会被删除。
+func (g *Group) setLocally(key string, value []byte) {
+	g.addToBloomFilter(key)
+	g.hotCache.remove(key)
+	g.negCache.remove(key)
+	g.populateCache(key, ByteView{b: cloneBytes(value)})
+}
+
+// 只在本节点删除，不转发。
+// 其他节点调用Set时，本节点会收到删除请求，此时key很可能存在，所以也将key加入布隆过滤器。
+// 布隆过滤器多加入的key只会增加误判率，不会导致存在的key被拒绝。
+func (g *Group) removeLocally(key string) {
+	g.addToBloomFilter(key)
+	g.mainCache.remove(key)
+	g.hotCache.remove(key)
+	g.negCache.remove(key)
+}
+
+// 并发地通知除skip以外的所有远程节点删除key，返回遇到的第一个错误。
+func (g *Group) broadcastRemove(ctx context.Context, key string, skip PeerGetter) error {
+	if g.peers == nil {
+		return nil
+	}
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+	)
+	for _, peer := range g.peers.GetAll() {
+		if peer == skip {
+			continue
+		}
+		wg.Add(1)
+		go func(peer PeerGetter) {
+			defer wg.Done()
+			req := &pb.Request{
+				Group: g.name,
+				Key:   key,
+			}
+			if err := peer.Remove(ctx, req, &pb.Response{}); err != nil {
+				errMu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMu.Unlock()
+			}
+		}(peer)
+	}
+	wg.Wait()
+	return firstErr
 }
 
 // RegisterPeers 将实现了PeerPicker接口的HTTPPool注入到Group中
@@ -101,20 +477,29 @@ func (g *Group) RegisterPeers(peers PeerPicker) {
 }
 
 // 使用PickPeer()方法选择节点，若非本机节点，则调用getFromPeer()从远程节点获取。若是本机节点或失败，则回退到getLocally()。
-func (g *Group) load(key string) (value ByteView, err error) {
+func (g *Group) load(ctx context.Context, key string) (value ByteView, err error) {
+	g.stats.Loads.Add(1)
 	// each key is only fetched once (either locally or remotely)
 	// regardless of the number of concurrent callers.
-	viewi, err := g.loader.Do(key, func() (interface{}, error) {
+	viewi, err, _ := g.loader.Do(ctx, key, func() (interface{}, error) {
 		// 将从其他节点或从数据库中获取数据，封装进方法中。确保只会执行一次。
-		if g.peers != nil {
+		ctx, cancel := g.loadContext(ctx)
+		defer cancel()
+		g.stats.LoadsDeduped.Add(1)
+		defer func(start time.Time) {
+			g.metrics.loadLatency.observe(time.Since(start))
+		}(time.Now())
+		// 其他节点转发来的请求只在本节点加载，不再转发。
+		if g.peers != nil && !isPeerRequest(ctx) {
 			if peer, ok := g.peers.PickPeer(key); ok {
-				if value, err := g.getFromPeer(peer, key); err == nil {
-					return value, nil
+				value, err := g.loadFromPeer(ctx, peer, key)
+				if peerDone(ctx, err) {
+					return value, err
 				}
-				log.Println("[GoCache] Failed to get from peer", err)
+				return g.loadFallback(ctx, key, err)
 			}
 		}
-		return g.getLocally(key)
+		return g.loadLocally(ctx, key)
 	})
 
 	if err == nil {
@@ -123,14 +508,149 @@ func (g *Group) load(key string) (value ByteView, err error) {
 	return
 }
 
+// detachedContext 保留父ctx中的值（例如NewPeerContext的标记），但不会随父ctx取消，也没有截止时间。
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+// 返回singleflight中共享的加载使用的ctx。加载的结果由所有等待的调用方共享，不能随发起请求的调用方取消，
+// 否则其他ctx仍然有效的调用方也会收到context.Canceled；每个调用方在自己的ctx结束时放弃等待，加载本身由loadTimeout限制。
+func (g *Group) loadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if g.loadTimeout <= 0 {
+		return context.WithCancel(detachedContext{ctx})
+	}
+	return context.WithTimeout(detachedContext{ctx}, g.loadTimeout)
+}
+
+// 从远程节点peer获取key，并更新统计数据、hotCache和负缓存。
+func (g *Group) loadFromPeer(ctx context.Context, peer PeerGetter, key string) (interface{}, error) {
+	value, err := g.getFromPeer(ctx, peer, key)
+	switch {
+	case err == nil:
+		g.stats.PeerLoads.Add(1)
+		// 只对部分从远程节点获取的值进行缓存，被频繁访问的热点key更有可能被缓存。
+		if g.hotSampleRate > 0 && rand.Intn(g.hotSampleRate) == 0 {
+			g.hotCache.add(key, value, g.expire())
+		}
+		return value, nil
+	case errors.Is(err, ErrNotFound):
+		// 负责该key的节点已经确认数据不存在，不需要再回退到本地加载。
+		g.stats.PeerLoads.Add(1)
+		g.populateNegativeCache(key)
+		return nil, err
+	}
+	g.stats.PeerErrors.Add(1)
+	err = &PeerError{Peer: peerName(peer), Key: key, Err: err}
+	log.Println("[GoCache]", err)
+	return nil, err
+}
+
+// 判断访问远程节点的结果是否是最终结果：获取成功、数据不存在，或者调用方已经放弃等待。
+func peerDone(ctx context.Context, err error) bool {
+	return err == nil || errors.Is(err, ErrNotFound) || ctx.Err() != nil
+}
+
+// 从负责key的远程节点获取失败（错误为err）后，按照PeerPolicy重试负责key的节点，仍然失败时按照Fallback继续加载。
+// 返回的错误是最后一个远程节点的*PeerError。
+func (g *Group) loadFallback(ctx context.Context, key string, err error) (interface{}, error) {
+	policy := g.peerPolicy
+	for i := 0; i < policy.Retries; i++ {
+		if sleepErr := sleep(ctx, policy.backoff(i)); sleepErr != nil {
+			return nil, err
+		}
+		peer, ok := g.peers.PickPeer(key)
+		if !ok {
+			// 节点列表已经变化，本节点成为负责key的节点。
+			return g.loadLocally(ctx, key)
+		}
+		var value interface{}
+		if value, err = g.loadFromPeer(ctx, peer, key); peerDone(ctx, err) {
+			return value, err
+		}
+	}
+
+	switch policy.Fallback {
+	case FallbackLocal:
+		return g.loadLocally(ctx, key)
+	case FallbackNextPeer:
+		rp, ok := g.peers.(ReplicaPicker)
+		if !ok {
+			break
+		}
+		n := policy.NextReplicas
+		if n <= 0 {
+			n = 1
+		}
+		replicas := rp.PickReplicas(key, n+1)
+		for i := 1; i < len(replicas); i++ {
+			if replicas[i] == nil {
+				// 后继节点是本节点，由本节点代替负责key的节点加载。
+				return g.loadLocally(ctx, key)
+			}
+			var value interface{}
+			if value, err = g.loadFromPeer(ctx, replicas[i], key); peerDone(ctx, err) {
+				return value, err
+			}
+		}
+	}
+	return nil, err
+}
+
+// 调用回调函数加载key，并更新统计数据和负缓存。
+func (g *Group) loadLocally(ctx context.Context, key string) (interface{}, error) {
+	value, err := g.getLocally(ctx, key)
+	if err != nil {
+		g.stats.LocalLoadErrs.Add(1)
+		if errors.Is(err, ErrNotFound) {
+			g.populateNegativeCache(key)
+		}
+		return nil, err
+	}
+	g.stats.LocalLoads.Add(1)
+	return value, nil
+}
+
 // 将键值对数据添加到分布式缓存Cache中。
+// 开启stale-while-revalidate时，记录保留到软过期后max(staleTTL, maxStale)，以便刷新失败时返回旧值。
 func (g *Group) populateCache(key string, value ByteView) {
-	g.mainCache.add(key, value)
+	if g.poolConfig != nil {
+		defer g.poolConfig.pool.enforce()
+	}
+	if g.staleTTL <= 0 || g.ttl <= 0 {
+		g.mainCache.add(key, value, g.expire())
+		return
+	}
+	value.stale = time.Now().Add(g.ttl)
+	value.expire = value.stale.Add(g.staleTTL)
+	keep := g.staleTTL
+	if g.maxStale > keep {
+		keep = g.maxStale
+	}
+	g.mainCache.add(key, value, value.stale.Add(keep))
+}
+
+// 开启负缓存时，记录key对应的数据不存在，negativeTTL后过期。
+func (g *Group) populateNegativeCache(key string) {
+	if g.negativeTTL > 0 {
+		g.negCache.add(key, ByteView{}, time.Now().Add(g.negativeTTL))
+	}
+}
+
+// 根据默认过期时间ttl，计算新缓存项的过期时间。零值代表永不过期。
+func (g *Group) expire() time.Time {
+	if g.ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(g.ttl)
 }
 
-func (g *Group) getLocally(key string) (ByteView, error) {
+func (g *Group) getLocally(ctx context.Context, key string) (ByteView, error) {
 	// 调用开发者传递的回调函数，从本地获取数据。
-	bytes, err := g.getter.Get(key)
+	bytes, err := g.getter.Get(ctx, key)
 	if err != nil {
 		return ByteView{}, err
 	}
@@ -142,13 +662,15 @@ func (g *Group) getLocally(key string) (ByteView, error) {
 }
 
 // 使用实现了PeerGetter接口的httpGetter访问远程节点，获取缓存值。
-func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
+func (g *Group) getFromPeer(ctx context.Context, peer PeerGetter, key string) (ByteView, error) {
 	req := &pb.Request{
 		Group: g.name,
-		Key: key,
+		Key:   key,
 	}
 	res := &pb.Response{}
-	err := peer.Get(req, res)
+	start := time.Now()
+	err := peer.Get(ctx, req, res)
+	g.metrics.observePeer(peerName(peer), time.Since(start))
 	if err != nil {
 		return ByteView{}, err
 	}
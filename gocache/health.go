@@ -0,0 +1,237 @@
+package gocache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 健康检查的路径，完整地址为http://example.com/_gocache/health
+const healthPath = "health"
+
+// HealthConfig 主动健康检查的参数，零值字段使用默认值。
+type HealthConfig struct {
+	Interval  time.Duration // 探测间隔，默认5秒。
+	Timeout   time.Duration // 单次探测的超时时间，默认1秒。
+	Threshold int           // 连续探测失败多少次后标记节点下线，默认2。探测成功一次即标记上线。
+}
+
+// PeerHealth 本节点看到的某个远程节点的状态。
+type PeerHealth struct {
+	Peer      string        // 远程节点的地址。
+	Up        bool          // 健康检查是否认为节点在线，下线的节点不参与路由。
+	Breaker   BreakerState  // 熔断器的状态。
+	Checks    int64         // 探测的次数。
+	Failures  int64         // 探测失败的次数。
+	LastCheck time.Time     // 最近一次探测的时间，零值代表还没有探测过。
+	LastError string        // 最近一次探测失败的原因，探测成功后清空。
+	Latency   time.Duration // 最近一次探测的耗时。
+}
+
+// 远程节点的健康检查状态，保存在httpGetter中。还没有探测过的节点视为在线。
+type peerHealth struct {
+	mu          sync.Mutex
+	down        bool
+	consecutive int // 连续探测失败的次数。
+	checks      int64
+	failures    int64
+	lastCheck   time.Time
+	lastError   string
+	latency     time.Duration
+}
+
+// 判断节点是否在线。
+func (h *peerHealth) up() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return !h.down
+}
+
+// 记录一次探测的结果，返回节点的在线状态是否发生了变化。
+func (h *peerHealth) record(err error, latency time.Duration, threshold int) (changed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks++
+	h.lastCheck = time.Now()
+	h.latency = latency
+	if err == nil {
+		h.consecutive = 0
+		h.lastError = ""
+		changed = h.down
+		h.down = false
+		return changed
+	}
+	h.failures++
+	h.consecutive++
+	h.lastError = err.Error()
+	if !h.down && h.consecutive >= threshold {
+		h.down = true
+		return true
+	}
+	return false
+}
+
+// StartHealthCheck 启动后台协程，每隔Interval探测一次哈希环上所有的远程节点。
+// 连续探测失败的节点被标记为下线，不参与PickPeer和PickReplicas的路由，它负责的key交给哈希环上的下一个节点；
+// 探测成功后重新上线。已经启动时再次调用不做任何事。
+func (p *HTTPPool) StartHealthCheck(cfg HealthConfig) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Second
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = 2
+	}
+	p.mu.Lock()
+	if p.healthStop != nil {
+		p.mu.Unlock()
+		return
+	}
+	p.healthCfg = cfg
+	stop := make(chan struct{})
+	p.healthStop = stop
+	p.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			p.checkHealth()
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// StopHealthCheck 停止后台探测，节点保持最后一次探测得到的状态。
+func (p *HTTPPool) StopHealthCheck() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.healthStop != nil {
+		close(p.healthStop)
+		p.healthStop = nil
+	}
+}
+
+// 并发地探测所有远程节点一次，等待全部探测结束。
+func (p *HTTPPool) checkHealth() {
+	p.mu.Lock()
+	cfg := p.healthCfg
+	getters := make(map[string]*httpGetter, len(p.httpGetters))
+	for peer, getter := range p.httpGetters {
+		if peer != p.self {
+			getters[peer] = getter
+		}
+	}
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for peer, getter := range getters {
+		wg.Add(1)
+		go func(peer string, getter *httpGetter) {
+			defer wg.Done()
+			start := time.Now()
+			err := getter.ping(cfg.Timeout)
+			if getter.health.record(err, time.Since(start), cfg.Threshold) {
+				if err != nil {
+					p.Log("peer %s is down: %v", peer, err)
+				} else {
+					p.Log("peer %s is up", peer)
+				}
+			}
+		}(peer, getter)
+	}
+	wg.Wait()
+}
+
+// 请求远程节点的健康检查地址，返回200以外的状态码都视为失败。探测请求不计入熔断器。
+func (h *httpGetter) ping(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.baseURL+healthPath, nil)
+	if err != nil {
+		return err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned: %v", res.Status)
+	}
+	return nil
+}
+
+// PeerHealth 返回本节点看到的所有远程节点的状态，按地址排序。
+func (p *HTTPPool) PeerHealth() []PeerHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	all := make([]PeerHealth, 0, len(p.httpGetters))
+	for peer, getter := range p.httpGetters {
+		if peer == p.self {
+			continue
+		}
+		h := &getter.health
+		h.mu.Lock()
+		all = append(all, PeerHealth{
+			Peer:      peer,
+			Up:        !h.down,
+			Breaker:   getter.breaker.current(),
+			Checks:    h.checks,
+			Failures:  h.failures,
+			LastCheck: h.lastCheck,
+			LastError: h.lastError,
+			Latency:   h.latency,
+		})
+		h.mu.Unlock()
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Peer < all[j].Peer })
+	return all
+}
+
+// MarshalText 使BreakerState在JSON中显示为状态的名称。
+func (s BreakerState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// AdminHandler 返回以JSON格式输出本节点状态的http.Handler：本节点认为在线和下线的远程节点，
+// 以及所有Group的统计数据。与MetricsHandler一样由调用方注册，例如/admin路径。
+func (p *HTTPPool) AdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.RLock()
+		all := make([]*Group, 0, len(groups))
+		for _, g := range groups {
+			all = append(all, g)
+		}
+		mu.RUnlock()
+
+		out := struct {
+			Self   string
+			Peers  []PeerHealth
+			Groups map[string]Stats
+		}{
+			Self:   p.self,
+			Peers:  p.PeerHealth(),
+			Groups: make(map[string]Stats, len(all)),
+		}
+		for _, g := range all {
+			out.Groups[g.name] = g.Stats()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package gocache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthCheck(t *testing.T) {
+	up := httptest.NewServer(NewHTTPPool("up"))
+	defer up.Close()
+	// flaky在healthy为false时返回503。
+	var healthy atomic.Bool
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer flaky.Close()
+
+	pool := NewHTTPPool("http://self")
+	pool.Set(up.URL, flaky.URL, "http://self")
+	pool.healthCfg = HealthConfig{Timeout: time.Second, Threshold: 2}
+	var key string
+	for i := 0; pool.peers.Get(key) != flaky.URL; i++ {
+		key = fmt.Sprintf("key%d", i)
+	}
+
+	// 连续失败未达到阈值时仍然在线。
+	pool.checkHealth()
+	if peer, ok := pool.PickPeer(key); !ok || peer != pool.httpGetters[flaky.URL] {
+		t.Fatalf("peer should stay up below threshold, got %v", peer)
+	}
+	pool.checkHealth()
+	if peer, ok := pool.PickPeer(key); ok && peer == pool.httpGetters[flaky.URL] {
+		t.Fatal("down peer should not be picked")
+	}
+	for _, r := range pool.PickReplicas(key, 3) {
+		if r == pool.httpGetters[flaky.URL] {
+			t.Fatal("down peer should not be returned by PickReplicas")
+		}
+	}
+
+	health := pool.PeerHealth()
+	if len(health) != 2 {
+		t.Fatalf("expected 2 peers, got %+v", health)
+	}
+	byPeer := map[string]PeerHealth{health[0].Peer: health[0], health[1].Peer: health[1]}
+	if h := byPeer[up.URL]; !h.Up || h.Checks != 2 || h.Failures != 0 || h.LastCheck.IsZero() {
+		t.Fatalf("unexpected health %+v", h)
+	}
+	if h := byPeer[flaky.URL]; h.Up || h.Checks != 2 || h.Failures != 2 || h.LastError == "" {
+		t.Fatalf("unexpected health %+v", h)
+	}
+
+	// 探测成功一次即重新上线。
+	healthy.Store(true)
+	pool.checkHealth()
+	if peer, ok := pool.PickPeer(key); !ok || peer != pool.httpGetters[flaky.URL] {
+		t.Fatalf("recovered peer should be picked, got %v", peer)
+	}
+	if h := pool.PeerHealth(); !h[0].Up || !h[1].Up {
+		t.Fatalf("all peers should be up, got %+v", h)
+	}
+}
+
+func TestAdminHandler(t *testing.T) {
+	NewGroup("admin", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	pool := NewHTTPPool("http://self")
+	pool.Set("http://self", "http://a")
+	pool.httpGetters["http://a"].health.record(errors.New("connection refused"), time.Millisecond, 1)
+
+	w := httptest.NewRecorder()
+	pool.AdminHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+	var out struct {
+		Self  string
+		Peers []struct {
+			Peer    string
+			Up      bool
+			Breaker string
+		}
+		Groups map[string]map[string]interface{}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Self != "http://self" || len(out.Peers) != 1 || out.Peers[0].Peer != "http://a" || out.Peers[0].Up || out.Peers[0].Breaker != "closed" {
+		t.Fatalf("unexpected admin output %s", w.Body)
+	}
+	if _, ok := out.Groups["admin"]["Gets"]; !ok {
+		t.Fatalf("expected group stats, got %s", w.Body)
+	}
+
+	// 健康检查地址。
+	w = httptest.NewRecorder()
+	pool.ServeHTTP(w, httptest.NewRequest(http.MethodGet, defaultBashPath+healthPath, nil))
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Fatalf("unexpected health response %d %s", w.Code, w.Body)
+	}
+}
@@ -3,6 +3,9 @@ package gocache
 import (
 	"GoCache/gocache/consistenthash"
 	pb "GoCache/gocachepb"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"google.golang.org/protobuf/proto"
 	"io"
@@ -11,11 +14,14 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 )
 
 const (
 	defaultBashPath = "/_gocache/"
 	defaultReplicas = 50
+	// 远程节点返回404时，带有该响应头代表数据不存在（ErrNotFound），否则代表group不存在。
+	notFoundHeader = "X-GoCache-Not-Found"
 )
 
 // HTTPPool 以http://example.com/_gocache/开头的请求，用于节点间的访问。
@@ -25,14 +31,38 @@ type HTTPPool struct {
 	mu          sync.Mutex             // 互斥锁。
 	peers       *consistenthash.Map    // 一致性哈希算法的Map，用来根据具体的key来选择节点。
 	httpGetters map[string]*httpGetter // 映射远程节点和对应的httpGetter。每一个远程节点对应一个httpGetter。因为httpGetter与远程节点的地址baseURL有关。
+
+	breakerThreshold int           // 连续失败多少次后断开远程节点的熔断器，<= 0代表不使用熔断器。
+	breakerCooldown  time.Duration // 熔断器断开多久之后允许一次试探请求。
+
+	healthCfg  HealthConfig  // 主动健康检查的参数。
+	healthStop chan struct{} // 关闭时停止健康检查，为nil代表没有启动。
+}
+
+// HTTPPoolOption HTTPPool的可选配置。
+type HTTPPoolOption func(*HTTPPool)
+
+// WithCircuitBreaker 设置远程节点的熔断器：连续失败threshold次后断开，节点暂时不参与路由，
+// 它负责的key交给哈希环上的下一个节点；cooldown之后允许一次试探请求，成功时恢复。threshold <= 0代表不使用熔断器。
+func WithCircuitBreaker(threshold int, cooldown time.Duration) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.breakerThreshold = threshold
+		p.breakerCooldown = cooldown
+	}
 }
 
 // NewHTTPPool HTTPPool的实例化方法。
-func NewHTTPPool(self string) *HTTPPool {
-	return &HTTPPool{
-		self:     self,
-		bashPath: defaultBashPath,
+func NewHTTPPool(self string, opts ...HTTPPoolOption) *HTTPPool {
+	p := &HTTPPool{
+		self:             self,
+		bashPath:         defaultBashPath,
+		breakerThreshold: defaultBreakerThreshold,
+		breakerCooldown:  defaultBreakerCooldown,
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
+	return p
 }
 
 // Log 打印日志信息。
@@ -47,6 +77,11 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// 如果请求的URL不是以basePath（"/_gocache/"）开头。
 		panic("HTTPPool serving unexpected path: " + r.URL.Path)
 	}
+	if r.URL.Path == p.bashPath+healthPath {
+		// 其他节点的健康检查，频率较高，不打印日志。
+		w.Write([]byte("ok"))
+		return
+	}
 	p.Log("%s %s", r.Method, r.URL.Path)
 	// Path[len(p.basePath):] 代表请求的URL截去basePath的部分。SplitN代表将剩下的部分，按照"/"分割成两部分。
 	parts := strings.SplitN(r.URL.Path[len(p.bashPath):], "/", 2)
@@ -66,14 +101,67 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 在group中获取value。
-	view, err := group.Get(key)
+	switch r.Method {
+	case http.MethodPut:
+		// 其他节点转发的写入请求，请求体是SetRequest。只在本节点写入，不再转发，避免循环。
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		in := &pb.SetRequest{}
+		if err = proto.Unmarshal(body, in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		group.setLocally(key, in.GetValue())
+		writeResponse(w, &pb.Response{})
+		return
+	case http.MethodDelete:
+		// 其他节点转发的删除请求，只在本节点删除。
+		group.removeLocally(key)
+		writeResponse(w, &pb.Response{})
+		return
+	case http.MethodPost:
+		// 批量查询，请求的URL为/_gocache/<group>/，请求体是MultiRequest。
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		in := &pb.MultiRequest{}
+		if err = proto.Unmarshal(body, in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		values, missing, _ := group.getMulti(NewPeerContext(r.Context()), in.GetKeys())
+		out := &pb.MultiResponse{Values: make(map[string][]byte, len(values)), Missing: missing}
+		for k, v := range values {
+			out.Values[k] = v.ByteSlice()
+		}
+		writeResponse(w, out)
+		return
+	}
+
+	// 在group中获取value。请求来自其他节点，只在本节点加载，不再转发。
+	view, err := group.Get(NewPeerContext(r.Context()), key)
 	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			// 使用响应头区分数据不存在和group不存在。
+			w.Header().Set(notFoundHeader, "1")
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	// Write the value to the response body as a proto message.
-	body, err := proto.Marshal(&pb.Response{Value: view.ByteSlice()})
+	writeResponse(w, &pb.Response{Value: view.ByteSlice()})
+}
+
+// 将proto消息编码后写入响应体。
+func writeResponse(w http.ResponseWriter, out proto.Message) {
+	body, err := proto.Marshal(out)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -91,71 +179,225 @@ func (p *HTTPPool) Set(peers ...string) {
 	p.peers = consistenthash.New(defaultReplicas, nil)
 	// 加入多个真实节点。
 	p.peers.Add(peers...)
-	// 初始化httpGetters
-	p.httpGetters = make(map[string]*httpGetter, len(peers))
+	// 初始化httpGetters，仍在集群中的节点沿用原来的httpGetter，保留熔断器和健康检查的状态。
+	getters := make(map[string]*httpGetter, len(peers))
 	for _, peer := range peers {
+		if getter, ok := p.httpGetters[peer]; ok {
+			getters[peer] = getter
+			continue
+		}
 		// peer:"http://localhost:8001" bashPath: /_gocache/
 		// bashURL: "http://localhost:8001/_gocache/"
-		p.httpGetters[peer] = &httpGetter{baseURL: peer + p.bashPath}
+		getters[peer] = &httpGetter{
+			baseURL: peer + p.bashPath,
+			breaker: newCircuitBreaker(p.breakerThreshold, p.breakerCooldown),
+		}
 	}
+	p.httpGetters = getters
 }
 
 // PickPeer 包装了一致性哈希算法的Get()方法，根据具体的key，选择节点，返回节点对应的HTTP客户端。
+// 熔断器断开或者下线的节点被跳过，key交给哈希环上的下一个节点；轮到本节点时返回false，在本地加载。
 func (p *HTTPPool) PickPeer(key string) (PeerGetter, bool) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	if peer := p.peers.Get(key); peer != "" && peer != p.self {
+	if p.peers == nil {
+		return nil, false
+	}
+	for _, peer := range p.route(key, 1) {
+		if peer == p.self {
+			return nil, false
+		}
 		p.Log("Pick peer %s", peer)
 		return p.httpGetters[peer], true
 	}
 	return nil, false
 }
 
+// PickReplicas 按照哈希环的顺序返回负责key的前n个不同节点的HTTP客户端，本节点用nil表示。熔断器断开或者下线的节点被跳过。
+func (p *HTTPPool) PickReplicas(key string, n int) []PeerGetter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.peers == nil {
+		return nil
+	}
+	var replicas []PeerGetter
+	for _, peer := range p.route(key, n) {
+		if peer == p.self {
+			replicas = append(replicas, nil)
+		} else {
+			replicas = append(replicas, p.httpGetters[peer])
+		}
+	}
+	return replicas
+}
+
+// 按照哈希环的顺序返回负责key的前n个可用节点，跳过熔断器断开或者健康检查认为下线的远程节点。需要持有p.mu。
+func (p *HTTPPool) route(key string, n int) []string {
+	var nodes []string
+	for _, peer := range p.peers.GetN(key, len(p.httpGetters)+1) {
+		if len(nodes) == n {
+			break
+		}
+		if getter, ok := p.httpGetters[peer]; ok && peer != p.self && (!getter.breaker.available() || !getter.health.up()) {
+			continue
+		}
+		nodes = append(nodes, peer)
+	}
+	return nodes
+}
+
+// BreakerStates 返回每个远程节点熔断器的当前状态。
+func (p *HTTPPool) BreakerStates() map[string]BreakerState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	states := make(map[string]BreakerState, len(p.httpGetters))
+	for peer, getter := range p.httpGetters {
+		if peer != p.self {
+			states[peer] = getter.breaker.current()
+		}
+	}
+	return states
+}
+
+// GetAll 返回除本节点以外所有节点的HTTP客户端。
+func (p *HTTPPool) GetAll() []PeerGetter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	all := make([]PeerGetter, 0, len(p.httpGetters))
+	for peer, getter := range p.httpGetters {
+		if peer != p.self {
+			all = append(all, getter)
+		}
+	}
+	return all
+}
+
 // 确保HTTPPool实现了PeerPicker接口，如果没有实现，在编译期就会报错。
 var _ PeerPicker = (*HTTPPool)(nil)
+var _ ReplicaPicker = (*HTTPPool)(nil)
 
 // 实现PeerGetter接口。
 type httpGetter struct {
 	// 表示将要访问的远程节点的地址，例如http://example.com/_gocache/
 	baseURL string
+	// 远程节点的熔断器，为nil时不使用熔断器。
+	breaker *circuitBreaker
+	// 主动健康检查得到的远程节点状态。
+	health peerHealth
+}
+
+func (h *httpGetter) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	return h.do(ctx, http.MethodGet, in.GetGroup(), in.GetKey(), nil, out)
+}
+
+// Set 使用PUT请求在远程节点写入缓存值，请求体是SetRequest。
+func (h *httpGetter) Set(ctx context.Context, in *pb.SetRequest, out *pb.Response) error {
+	body, err := proto.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return h.do(ctx, http.MethodPut, in.GetGroup(), in.GetKey(), body, out)
+}
+
+// Remove 使用DELETE请求在远程节点删除缓存值。
+func (h *httpGetter) Remove(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	return h.do(ctx, http.MethodDelete, in.GetGroup(), in.GetKey(), nil, out)
+}
+
+// GetMulti 使用POST请求在远程节点批量查询缓存值，请求体是MultiRequest。
+func (h *httpGetter) GetMulti(ctx context.Context, in *pb.MultiRequest, out *pb.MultiResponse) error {
+	body, err := proto.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return h.do(ctx, http.MethodPost, in.GetGroup(), "", body, out)
 }
 
-func (h *httpGetter) Get(in *pb.Request, out *pb.Response) error {
+// 向远程节点发送请求，并将响应体解码到out中，同时把结果记录到熔断器。
+// 熔断器断开时不发送请求，直接返回ErrCircuitOpen。
+func (h *httpGetter) do(ctx context.Context, method, group, key string, body []byte, out proto.Message) error {
+	if !h.breaker.allow() {
+		return ErrCircuitOpen
+	}
+	err := h.send(ctx, method, group, key, body, out)
+	if errors.Is(ctx.Err(), context.Canceled) {
+		// 调用方主动取消的请求不能说明远程节点的状态。超时仍然记录为失败，没有响应的节点只会表现为超时。
+		h.breaker.cancel()
+		return err
+	}
+	h.breaker.record(!isPeerFailure(err))
+	return err
+}
+
+// peerFailure 远程节点本身不可用的错误：连接失败、网关错误，或者响应不完整。
+type peerFailure struct {
+	err error
+}
+
+func (e *peerFailure) Error() string { return e.err.Error() }
+func (e *peerFailure) Unwrap() error { return e.err }
+
+// 判断错误是否应该计入熔断器。数据不存在、group不存在、远程节点加载数据失败，都说明远程节点是可用的。
+func isPeerFailure(err error) bool {
+	var f *peerFailure
+	return errors.As(err, &f)
+}
+
+// 发送请求，并将响应体解码到out中。ctx结束时请求被取消。
+func (h *httpGetter) send(ctx context.Context, method, group, key string, body []byte, out proto.Message) error {
 	// 拼接url，准备发送请求。
 	// bashURL: "http://localhost:8001/_gocache/"	group: "scores"		key: "Tom"
 	u := fmt.Sprintf(
 		"%v%v/%v",
 		h.baseURL,
 		// QueryEscape函数对参数进行转码使之可以安全的用在URL查询里。
-		url.QueryEscape(in.GetGroup()),
-		url.QueryEscape(in.GetKey()),
+		url.QueryEscape(group),
+		url.QueryEscape(key),
 	)
-	// 使用http.Get()方式获取返回值，并转换为[]byte类型。
-	// http.Get函数返回值是 *Response和error。
-	res, err := http.Get(u)
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
+	// http.DefaultClient.Do函数返回值是 *Response和error。
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return &peerFailure{err}
+	}
 	// Response中的Body是ReaderCloser类型（Reader and Closer）。
 	defer res.Body.Close()
 
+	if res.StatusCode == http.StatusNotFound && res.Header.Get(notFoundHeader) != "" {
+		// 远程节点确认数据不存在。
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("server returned: %v", res.Status)
+		err = fmt.Errorf("server returned: %v", res.Status)
+		switch res.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return &peerFailure{err}
+		}
+		return err
 	}
 
 	// func ReadAll(r Reader) ([]byte, error)
 	// ReadAll()函数接收一个Reader，返回[]byte。
-	bytes, err := io.ReadAll(res.Body)
+	resBody, err := io.ReadAll(res.Body)
 	if err != nil {
-		return fmt.Errorf("reading response body: %v", err)
+		return &peerFailure{fmt.Errorf("reading response body: %v", err)}
 	}
 
-	if err = proto.Unmarshal(bytes, out); err != nil {
+	if err = proto.Unmarshal(resBody, out); err != nil {
 		return fmt.Errorf("decoing response body: %v", err)
 	}
 
 	return nil
 }
 
+// String 返回远程节点的地址，用作指标的标签。
+func (h *httpGetter) String() string {
+	return h.baseURL
+}
+
 // 确保httpGetter实现了PeerGetter接口，如果没有实现，编译期就会报错。
 var _ PeerGetter = (*httpGetter)(nil)
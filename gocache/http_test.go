@@ -1,9 +1,16 @@
 package gocache
 
 import (
+	pb "GoCache/gocachepb"
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestHasPrefix(t *testing.T) {
@@ -40,3 +47,263 @@ func TestSplitN(t *testing.T) {
 	parts3 := strings.SplitN(path3[len(bashPath):], "/", 2)
 	fmt.Println(parts3) // [first k1/v1]
 }
+
+func TestHTTPGetter(t *testing.T) {
+	NewGroup("http", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte("db:" + key), nil
+		}))
+	server := httptest.NewServer(NewHTTPPool("self"))
+	defer server.Close()
+	getter := &httpGetter{baseURL: server.URL + defaultBashPath}
+
+	get := func(key string) string {
+		out := &pb.Response{}
+		if err := getter.Get(context.Background(), &pb.Request{Group: "http", Key: key}, out); err != nil {
+			t.Fatal(err)
+		}
+		return string(out.GetValue())
+	}
+
+	if v := get("Tom"); v != "db:Tom" {
+		t.Fatalf("expected db:Tom, got %s", v)
+	}
+	if err := getter.Set(context.Background(), &pb.SetRequest{Group: "http", Key: "Tom", Value: []byte("700")}, &pb.Response{}); err != nil {
+		t.Fatal(err)
+	}
+	if v := get("Tom"); v != "700" {
+		t.Fatalf("expected value written by Set, got %s", v)
+	}
+	if err := getter.Remove(context.Background(), &pb.Request{Group: "http", Key: "Tom"}, &pb.Response{}); err != nil {
+		t.Fatal(err)
+	}
+	if v := get("Tom"); v != "db:Tom" {
+		t.Fatalf("expected reload after Remove, got %s", v)
+	}
+}
+
+func TestHTTPGetterNotFound(t *testing.T) {
+	NewGroup("http-not-found", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return nil, fmt.Errorf("%s not exist: %w", key, ErrNotFound)
+		}))
+	server := httptest.NewServer(NewHTTPPool("self"))
+	defer server.Close()
+	getter := &httpGetter{baseURL: server.URL + defaultBashPath}
+
+	// 数据不存在时返回ErrNotFound，group不存在时返回其他错误。
+	err := getter.Get(context.Background(), &pb.Request{Group: "http-not-found", Key: "Tom"}, &pb.Response{})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	err = getter.Get(context.Background(), &pb.Request{Group: "no-such-group", Key: "Tom"}, &pb.Response{})
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected non ErrNotFound error for unknown group, got %v", err)
+	}
+}
+
+func TestHTTPGetterMulti(t *testing.T) {
+	NewGroup("http-multi", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			if v, ok := db[key]; ok {
+				return []byte(v), nil
+			}
+			return nil, fmt.Errorf("%s not exist: %w", key, ErrNotFound)
+		}))
+	server := httptest.NewServer(NewHTTPPool("self"))
+	defer server.Close()
+	getter := &httpGetter{baseURL: server.URL + defaultBashPath}
+
+	out := &pb.MultiResponse{}
+	in := &pb.MultiRequest{Group: "http-multi", Keys: []string{"Tom", "Jack", "unknown"}}
+	if err := getter.GetMulti(context.Background(), in, out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.GetValues()) != 2 || string(out.GetValues()["Tom"]) != "630" || string(out.GetValues()["Jack"]) != "589" {
+		t.Fatalf("unexpected values %v", out.GetValues())
+	}
+	if !reflect.DeepEqual(out.GetMissing(), []string{"unknown"}) {
+		t.Fatalf("expected unknown to be missing, got %v", out.GetMissing())
+	}
+}
+
+func TestPickReplicas(t *testing.T) {
+	pool := NewHTTPPool("http://b")
+	pool.Set("http://a", "http://b", "http://c")
+	for _, key := range []string{"Tom", "Jack", "Sam", "Kate"} {
+		replicas := pool.PickReplicas(key, 3)
+		if len(replicas) != 3 {
+			t.Fatalf("expected 3 replicas, got %v", replicas)
+		}
+		self := 0
+		for _, r := range replicas {
+			if r == nil {
+				self++
+			}
+		}
+		if self != 1 {
+			t.Fatalf("expected self to appear once, got %v", replicas)
+		}
+		if peer, ok := pool.PickPeer(key); ok != (replicas[0] != nil) || (ok && peer != replicas[0]) {
+			t.Fatalf("first replica %v should match PickPeer %v", replicas[0], peer)
+		}
+	}
+}
+
+func TestPickPeerCircuitBreaker(t *testing.T) {
+	NewGroup("breaker", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return nil, fmt.Errorf("%s not exist", key)
+		}))
+	// down返回503，up返回Getter的错误（500），两者都能连接。
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(NewHTTPPool("up"))
+	defer up.Close()
+
+	now := time.Unix(0, 0)
+	pool := NewHTTPPool("http://self", WithCircuitBreaker(2, time.Minute))
+	pool.Set(down.URL, up.URL, "http://self")
+	for _, getter := range pool.httpGetters {
+		getter.breaker.now = func() time.Time { return now }
+	}
+
+	keyOf := func(owner string) string {
+		for i := 0; ; i++ {
+			if key := fmt.Sprintf("key%d", i); pool.peers.Get(key) == owner {
+				return key
+			}
+		}
+	}
+	get := func(key string) error {
+		peer, ok := pool.PickPeer(key)
+		if !ok {
+			t.Fatalf("expected a remote peer for %s", key)
+		}
+		return peer.Get(context.Background(), &pb.Request{Group: "breaker", Key: key}, &pb.Response{})
+	}
+
+	// 远程节点加载失败不代表节点不可用。
+	upKey := keyOf(up.URL)
+	for i := 0; i < 3; i++ {
+		if err := get(upKey); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected getter error, got %v", err)
+		}
+	}
+
+	downKey := keyOf(down.URL)
+	for i := 0; i < 2; i++ {
+		if err := get(downKey); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected 503, got %v", err)
+		}
+	}
+	expect := map[string]BreakerState{down.URL: BreakerOpen, up.URL: BreakerClosed}
+	if states := pool.BreakerStates(); !reflect.DeepEqual(states, expect) {
+		t.Fatalf("expected %v, got %v", expect, states)
+	}
+	if err := pool.httpGetters[down.URL].Get(context.Background(), &pb.Request{Group: "breaker", Key: downKey}, &pb.Response{}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	// 断开的节点不参与路由，key交给哈希环上的下一个节点。
+	next := pool.peers.GetN(downKey, 2)[1]
+	peer, ok := pool.PickPeer(downKey)
+	if next == "http://self" {
+		if ok {
+			t.Fatalf("expected local load, got %v", peer)
+		}
+	} else if !ok || peer != pool.httpGetters[next] {
+		t.Fatalf("expected reroute to %s, got %v", next, peer)
+	}
+	for _, r := range pool.PickReplicas(downKey, 3) {
+		if r == pool.httpGetters[down.URL] {
+			t.Fatal("open peer should not be returned by PickReplicas")
+		}
+	}
+
+	// 重新设置节点时保留熔断器的状态。
+	pool.Set(down.URL, up.URL, "http://self")
+	if pool.BreakerStates()[down.URL] != BreakerOpen {
+		t.Fatal("breaker state should survive Set")
+	}
+
+	// 冷却时间过后节点重新参与路由，试探失败时再次断开。
+	now = now.Add(time.Minute)
+	if err := get(downKey); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected probe to reach peer, got %v", err)
+	}
+	if pool.BreakerStates()[down.URL] != BreakerOpen {
+		t.Fatal("failed probe should reopen breaker")
+	}
+}
+
+func TestHTTPGetterBreakerCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	now := time.Unix(0, 0)
+	getter := &httpGetter{baseURL: server.URL + defaultBashPath, breaker: newCircuitBreaker(1, time.Second)}
+	getter.breaker.now = func() time.Time { return now }
+	in := &pb.Request{Group: "breaker", Key: "Tom"}
+
+	getter.Get(context.Background(), in, &pb.Response{})
+	if getter.breaker.current() != BreakerOpen {
+		t.Fatalf("expected open breaker, got %s", getter.breaker.current())
+	}
+
+	// 试探请求的ctx已经结束，熔断器不能停留在没有试探名额的半开状态。
+	now = now.Add(time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := getter.Get(ctx, in, &pb.Response{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if !getter.breaker.available() {
+		t.Fatalf("breaker should be available for another probe, got %s", getter.breaker.current())
+	}
+	if err := getter.Get(context.Background(), in, &pb.Response{}); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected probe to reach peer, got %v", err)
+	}
+}
+
+func TestHTTPPoolBreakerTimeout(t *testing.T) {
+	// 没有响应的远程节点，只能等到请求超时。
+	hang := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer hang.Close()
+
+	group := NewGroup("breaker-timeout", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte("db:" + key), nil
+		}), WithLoadTimeout(50*time.Millisecond))
+	pool := NewHTTPPool("http://self", WithCircuitBreaker(1, time.Minute))
+	pool.Set(hang.URL, "http://self")
+	group.RegisterPeers(pool)
+	var keys []string
+	for i := 0; len(keys) < 2; i++ {
+		if key := fmt.Sprintf("key%d", i); pool.peers.Get(key) == hang.URL {
+			keys = append(keys, key)
+		}
+	}
+	key := keys[0]
+
+	// 加载超时计入熔断器，熔断器断开后key交给本节点，不再等待超时。
+	group.Get(context.Background(), key)
+	if state := pool.BreakerStates()[hang.URL]; state != BreakerOpen {
+		t.Fatalf("timeout should open the breaker, got %s", state)
+	}
+	if _, ok := pool.PickPeer(key); ok {
+		t.Fatal("open peer should not be picked")
+	}
+	start := time.Now()
+	if v, err := group.Get(context.Background(), keys[1]); err != nil || v.String() != "db:"+keys[1] {
+		t.Fatalf("unexpected value %v, %v", v, err)
+	}
+	if elapsed := time.Since(start); elapsed >= 50*time.Millisecond {
+		t.Fatalf("rerouted load should not wait for the timeout, took %v", elapsed)
+	}
+}
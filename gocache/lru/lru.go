@@ -1,106 +1,384 @@
 package lru
 
 import (
+	"GoCache/gocache/policy"
 	"container/list"
+	"time"
+	"unsafe"
 )
 
-// Cache 对外暴露的缓存对象。LRU缓存，在并发访问下线程不安全。
-type Cache struct {
+// Cache 对外暴露的缓存对象，key是string，值是实现了Value接口的任意类型。保留泛型之前的API，由New或NewWithPolicy创建。
+type Cache = TypedCache[string, Value]
+
+// TypedCache 泛型的缓存对象，K是key的类型，V是value的类型。默认使用LRU淘汰策略，在并发访问下线程不安全。
+type TypedCache[K comparable, V any] struct {
 	// 允许使用的最大内存。默认值0代表不设置内存大小。
 	maxBytes int64
+	// 允许保存的最大记录数。默认值0代表不限制，修改后在下一次Add时生效。
+	MaxEntries int
+	// 返回当前时间，用于记录每条记录最近一次被访问的时间（见SampleOldest）。为nil时不记录。
+	Clock func() int64
 	// 当前已经使用的内存。
 	useBytes int64
-	// Go语言标准库实现的双向链表。
-	ll *list.List
-	// 字典的定义，key是字符串，value是双向链表中对应节点的指针。
-	// Element结构体定义 next, prev *Element	list *List	Value any
-	cache map[string]*list.Element
-	// 某条记录被移除时的回调函数，可以为nil。
-	OnEvicted func(key string, value Value)
+	// 计算一条记录占用的内存大小。为nil时每条记录计为1，此时maxBytes相当于最大记录数。
+	size func(K, V) int64
+	// 淘汰策略，负责维护key的淘汰顺序，默认是LRU。
+	policy policy.Policy[K]
+	// 字典的定义，key是K类型的键，value是对应的记录。
+	cache map[K]*entry[K, V]
+	// 某条记录被移除（或者被新值替换）时的回调函数，可以为nil。reason代表移除的原因。
+	OnEvicted func(key K, value V, reason EvictReason)
+}
+
+// EvictReason 记录被移除的原因。
+type EvictReason int
+
+const (
+	// EvictCapacity 超过最大内存容量，被淘汰策略淘汰。
+	EvictCapacity EvictReason = iota + 1
+	// EvictExpired 记录过期。
+	EvictExpired
+	// EvictRemoved 调用Remove主动删除。
+	EvictRemoved
+	// EvictReplaced 值被Add更新，回调函数收到的是旧值。
+	EvictReplaced
+	// EvictCleared 调用Clear清空缓存。
+	EvictCleared
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictRemoved:
+		return "removed"
+	case EvictReplaced:
+		return "replaced"
+	case EvictCleared:
+		return "cleared"
+	default:
+		return "unknown"
+	}
 }
 
 // Value 为了通用性，值是实现了Value接口的任意类型，该接口只包含一个方法Len() int，用于返回值所占用的内存大小。
+// New和NewWithPolicy创建的Cache使用len(key) + value.Len()作为记录的内存大小。
 type Value interface {
 	Len() int
 }
 
-// 键值对entry是双向链表中节点的数据类型。在链表中保存每个值对应的key的好处在于淘汰队首节点时，需要用key从字典中删除对应的映射。
-// 因为链表中存入的只有值，那么删除/更新值的时候，无法得知值对应的键，也就无法在字典中删除/更新对应的记录。
-type entry struct {
-	key   string
-	value Value
+// 键值对entry是字典中保存的记录。在记录中保存key的好处在于淘汰策略选出淘汰的key后，可以直接用key从字典中删除对应的映射。
+type entry[K comparable, V any] struct {
+	key   K
+	value V
+	// 过期时间，零值代表永不过期。
+	expire time.Time
+	// 最近一次被访问的时间，只在设置了Clock时记录。
+	atime int64
+}
+
+// 判断记录在now时刻是否已经过期。
+func (e *entry[K, V]) expired(now time.Time) bool {
+	return !e.expire.IsZero() && now.After(e.expire)
 }
 
-// New 实例化函数。需要传递最大内存容量和回调函数。
-func New(maxBytes int64, onEvicted func(string, Value)) *Cache {
-	return &Cache{
+// New 实例化函数。需要传递最大内存容量和回调函数，使用LRU淘汰策略。
+func New(maxBytes int64, onEvicted func(string, Value, EvictReason)) *Cache {
+	return NewWithPolicy(maxBytes, onEvicted, policy.NewLRU[string]())
+}
+
+// NewWithPolicy 实例化函数，使用指定的淘汰策略p。p不能被多个Cache共用。
+func NewWithPolicy(maxBytes int64, onEvicted func(string, Value, EvictReason), p policy.Policy[string]) *Cache {
+	return NewCacheWithPolicy(maxBytes, valueSize, onEvicted, p)
+}
+
+// 计算字符串key和Value占用的内存大小。
+func valueSize(key string, value Value) int64 {
+	return int64(len(key)) + int64(value.Len())
+}
+
+// NewCache 泛型的实例化函数，使用LRU淘汰策略。size计算一条记录占用的内存大小，为nil时maxBytes代表最大记录数。
+func NewCache[K comparable, V any](maxBytes int64, size func(K, V) int64, onEvicted func(K, V, EvictReason)) *TypedCache[K, V] {
+	return NewCacheWithPolicy(maxBytes, size, onEvicted, policy.NewLRU[K]())
+}
+
+// NewCacheWithPolicy 泛型的实例化函数，使用指定的淘汰策略p。p不能被多个Cache共用。
+func NewCacheWithPolicy[K comparable, V any](maxBytes int64, size func(K, V) int64, onEvicted func(K, V, EvictReason), p policy.Policy[K]) *TypedCache[K, V] {
+	return &TypedCache[K, V]{
 		maxBytes:  maxBytes,
-		ll:        list.New(),
-		cache:     make(map[string]*list.Element),
+		size:      size,
+		policy:    p,
+		cache:     make(map[K]*entry[K, V]),
 		OnEvicted: onEvicted,
 	}
 }
 
-// Get 查找功能，分为两步，从字典中找出对应的双向链表的节点，将该节点移动到队尾。
-func (c *Cache) Get(key string) (value Value, ok bool) {
-	if ele, ok := c.cache[key]; ok {
-		// 如果键对应的链表节点存在，则将对应节点移动到队尾，并返回查找到的值。
-		// MoveToFront将链表中的节点ele移动到队尾（双向链表作为队列，队首和队尾是相对的，在这里约定front为队尾）。
-		c.ll.MoveToFront(ele)
-		// 双向链表存储的value为any类型（interface{}）type any = interface{}，这里需要强制转换成entry类型。
-		kv := ele.Value.(*entry)
+// EntryOverhead 估算使用默认的LRU策略时，每条记录除了key和value引用的内容（例如字符串的字节）以外额外占用的内存，
+// 包括entry结构体、字典中的槽位、LRU策略的链表节点和字典槽位。值很小时这部分开销远大于数据本身，
+// size函数应当加上EntryOverhead，才能让maxBytes接近实际占用的堆内存。
+func EntryOverhead[K comparable, V any]() int64 {
+	var (
+		key K
+		e   entry[K, V]
+		ele list.Element
+	)
+	keySize := unsafe.Sizeof(key)
+	return allocSize(unsafe.Sizeof(e)) + // entry结构体。
+		mapSlotSize(keySize, unsafe.Sizeof(&e)) + // Cache.cache中的槽位。
+		allocSize(unsafe.Sizeof(ele)) + // 链表节点。
+		allocSize(keySize) + // 链表节点中的key被装箱为interface{}。
+		mapSlotSize(keySize, unsafe.Sizeof(&ele)) // 策略中key到链表节点的字典槽位。
+}
+
+// 将大小为n的对象向上取整到内存分配器的规格（小对象按16字节对齐）。
+func allocSize(n uintptr) int64 {
+	if n <= 8 {
+		return 8
+	}
+	return int64((n + 15) &^ 15)
+}
+
+// 估算字典中一个槽位的大小：每个槽位有1字节的tophash，桶的装载因子在扩容前后介于约6.5/16和6.5/8之间，
+// 再加上溢出桶，实际分配的空间约为键值大小的2倍。
+func mapSlotSize(keySize, valueSize uintptr) int64 {
+	return int64(keySize+valueSize+1) * 2
+}
+
+// 计算一条记录占用的内存大小。
+func (c *TypedCache[K, V]) sizeOf(key K, value V) int64 {
+	if c.size == nil {
+		return 1
+	}
+	return c.size(key, value)
+}
+
+// Get 查找功能，分为两步，从字典中找出对应的记录，并通知淘汰策略该记录被访问了。
+func (c *TypedCache[K, V]) Get(key K) (value V, ok bool) {
+	if kv, ok := c.cache[key]; ok {
+		if kv.expired(time.Now()) {
+			// 惰性删除：记录已经过期，删除后按未命中处理。
+			c.removeEntry(kv, EvictExpired)
+			return value, false
+		}
+		// 对于LRU策略，会将对应节点移动到队尾。
+		c.policy.Access(key)
+		c.touch(kv)
 		return kv.value, true
 	}
 	return
 }
 
-// RemoveOldest 删除功能，实际上缓存淘汰。移除最近最少访问的节点（队首）。
-func (c *Cache) RemoveOldest() {
-	// Back函数，返回的是队头。因为我们规定了front为队尾，back为队头。
-	ele := c.ll.Back()
-	if ele != nil {
-		// 从双向链表中删除节点。
-		c.ll.Remove(ele)
-		kv := ele.Value.(*entry)
-		// 从字典中删除该条记录。
-		delete(c.cache, kv.key)
-		// 更新当所用的内存。
-		c.useBytes -= int64(len(kv.key)) + int64(kv.value.Len())
-		// 回调函数不为nil，调用回调函数。
-		if c.OnEvicted != nil {
-			c.OnEvicted(kv.key, kv.value)
+// Peek 查找key对应的值，但不通知淘汰策略，不影响淘汰顺序。过期的记录按未命中处理（但不删除）。
+func (c *TypedCache[K, V]) Peek(key K) (value V, ok bool) {
+	if kv, ok := c.cache[key]; ok && !kv.expired(time.Now()) {
+		return kv.value, true
+	}
+	return
+}
+
+// Contains 判断key是否在缓存中（且没有过期），不影响淘汰顺序。
+func (c *TypedCache[K, V]) Contains(key K) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+// Keys 返回所有没有过期的key，越晚被淘汰的key越靠前。对于LRU策略即从最近访问到最久未访问。
+func (c *TypedCache[K, V]) Keys() []K {
+	now := time.Now()
+	keys := make([]K, 0, len(c.cache))
+	for _, key := range c.policy.Keys() {
+		if kv, ok := c.cache[key]; ok && !kv.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Range 遍历所有没有过期的记录，顺序不确定，fn返回false时停止遍历。fn中不能修改Cache。
+func (c *TypedCache[K, V]) Range(fn func(key K, value V) bool) {
+	now := time.Now()
+	for _, kv := range c.cache {
+		if kv.expired(now) {
+			continue
+		}
+		if !fn(kv.key, kv.value) {
+			return
+		}
+	}
+}
+
+// Resize 修改最大内存容量，超出新容量的记录会被淘汰，返回被淘汰的记录数。maxBytes为0代表不设置内存大小。
+func (c *TypedCache[K, V]) Resize(maxBytes int64) int {
+	c.maxBytes = maxBytes
+	evicted := 0
+	for c.overflow(0, 0) {
+		if !c.removeVictim() {
+			break
 		}
+		evicted++
 	}
+	return evicted
 }
 
-// Add 新增/更新功能。
-func (c *Cache) Add(key string, value Value) {
-	if ele, ok := c.cache[key]; !ok {
-		// 字典中不存在这条记录，新增操作。新增一个节点到队尾。
-		ele := c.ll.PushFront(&entry{key, value})
-		// 添加记录到字典中。
-		c.cache[key] = ele
+// Clear 删除所有的记录，每条记录都会以EvictCleared调用回调函数。
+func (c *TypedCache[K, V]) Clear() {
+	for _, kv := range c.cache {
+		c.removeEntry(kv, EvictCleared)
+	}
+}
+
+// 设置了Clock时，记录kv被访问的时间。
+func (c *TypedCache[K, V]) touch(kv *entry[K, V]) {
+	if c.Clock != nil {
+		kv.atime = c.Clock()
+	}
+}
+
+// SampleOldest 随机抽查最多samples条记录（利用map遍历顺序的随机性），返回其中最久没有被访问的记录的key和访问时间。
+// 需要设置Clock。用于在多个Cache之间近似地比较新旧，类似Redis的近似LRU。
+func (c *TypedCache[K, V]) SampleOldest(samples int) (key K, atime int64, ok bool) {
+	for _, kv := range c.cache {
+		if samples <= 0 {
+			break
+		}
+		samples--
+		if !ok || kv.atime < atime {
+			key, atime, ok = kv.key, kv.atime, true
+		}
+	}
+	return
+}
+
+// Evict 以EvictCapacity的原因删除key对应的记录，返回记录是否存在。用于由外部（例如共享的内存池）决定淘汰哪条记录。
+func (c *TypedCache[K, V]) Evict(key K) bool {
+	if kv, ok := c.cache[key]; ok {
+		c.removeEntry(kv, EvictCapacity)
+		return true
+	}
+	return false
+}
+
+// RemoveOldest 删除功能，实际上缓存淘汰。移除淘汰策略选出的记录，对于LRU策略即最近最少访问的节点（队首）。
+func (c *TypedCache[K, V]) RemoveOldest() {
+	c.removeVictim()
+}
+
+// 淘汰策略选出的key已经从策略中移除，这里只需要从字典中删除。没有可以淘汰的记录时返回false。
+func (c *TypedCache[K, V]) removeVictim() bool {
+	key, ok := c.policy.Victim()
+	if !ok {
+		return false
+	}
+	if kv, ok := c.cache[key]; ok {
+		c.evict(kv, EvictCapacity)
+	}
+	return true
+}
+
+// Remove 删除key对应的记录，返回记录是否存在。
+func (c *TypedCache[K, V]) Remove(key K) bool {
+	if kv, ok := c.cache[key]; ok {
+		c.removeEntry(kv, EvictRemoved)
+		return true
+	}
+	return false
+}
+
+// RemoveExpired 主动删除过期的记录。借鉴Redis的定期删除策略，每次最多随机抽查samples条记录（利用map遍历顺序的随机性），
+// 返回被删除的记录数。调用方可以在删除比例较高时再次调用。
+func (c *TypedCache[K, V]) RemoveExpired(samples int) int {
+	now := time.Now()
+	removed := 0
+	for _, kv := range c.cache {
+		if samples <= 0 {
+			break
+		}
+		samples--
+		if kv.expired(now) {
+			c.removeEntry(kv, EvictExpired)
+			removed++
+		}
+	}
+	return removed
+}
+
+// 从淘汰策略和字典中删除记录kv。
+func (c *TypedCache[K, V]) removeEntry(kv *entry[K, V], reason EvictReason) {
+	c.policy.Remove(kv.key)
+	c.evict(kv, reason)
+}
+
+// 从字典中删除记录kv，更新已使用的内存并调用回调函数。
+func (c *TypedCache[K, V]) evict(kv *entry[K, V], reason EvictReason) {
+	// 从字典中删除该条记录。
+	delete(c.cache, kv.key)
+	// 更新当所用的内存。
+	c.useBytes -= c.sizeOf(kv.key, kv.value)
+	// 回调函数不为nil，调用回调函数。
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value, reason)
+	}
+}
+
+// Add 新增/更新功能，记录永不过期。
+func (c *TypedCache[K, V]) Add(key K, value V) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire 新增/更新功能，记录在expire时刻过期。expire为零值代表永不过期。
+func (c *TypedCache[K, V]) AddWithExpire(key K, value V, expire time.Time) {
+	if kv, ok := c.cache[key]; !ok {
+		// 字典中不存在这条记录，新增操作。先淘汰旧值腾出空间，避免LFU等策略直接淘汰刚加入、访问次数最少的新记录。
+		size := c.sizeOf(key, value)
+		for c.overflow(size, 1) {
+			if !c.removeVictim() {
+				break
+			}
+		}
+		// 添加记录到字典中，并通知淘汰策略。
+		kv := &entry[K, V]{key: key, value: value, expire: expire}
+		c.cache[key] = kv
+		c.policy.Add(key)
+		c.touch(kv)
 		// 更新已使用的内存。
-		c.useBytes += int64(len(key)) + int64(value.Len())
+		c.useBytes += size
 	} else {
-		// 字典中存在这条记录，则更新对应节点的值，并将节点移动到队尾。
-		c.ll.MoveToFront(ele)
-		// c.cache是一个map结构，key是字符串，value是双向链表中对应节点的指针。
-		// Element中的Value为any类型（interface{}），所以需要强转为entry类型。
-		kv := ele.Value.(*entry)
+		// 字典中存在这条记录，则更新对应记录的值。对于LRU策略，会将对应节点移动到队尾。
+		c.policy.Access(key)
 		// 更新已经使用内存，oldVal代表旧值所占内存，newVal代表新值所占内存。
 		// oldVal < newVal，newVal - oldVal为正值。已使用内存增加。
 		// oldVal > newVal，newVal - oldVal为负值。已使用内存减小。
-		c.useBytes += int64(value.Len()) - int64(kv.value.Len())
+		c.useBytes += c.sizeOf(key, value) - c.sizeOf(key, kv.value)
+		old := kv.value
 		kv.value = value
+		kv.expire = expire
+		c.touch(kv)
+		if c.OnEvicted != nil {
+			c.OnEvicted(key, old, EvictReplaced)
+		}
 	}
-	// 如果添加/更新元素后，超过了最大的内存容量，循环淘汰旧值。
-	for c.maxBytes != 0 && c.maxBytes < c.useBytes {
-		c.RemoveOldest()
+	// 如果更新元素后（或者新记录本身就超过了最大内存容量），超过了最大的内存容量，循环淘汰旧值。
+	for c.overflow(0, 0) {
+		if !c.removeVictim() {
+			break
+		}
 	}
 }
 
+// 判断再加入bytes字节、entries条记录后是否超过最大内存容量或者最大记录数。
+func (c *TypedCache[K, V]) overflow(bytes int64, entries int) bool {
+	return (c.maxBytes != 0 && c.maxBytes < c.useBytes+bytes) ||
+		(c.MaxEntries != 0 && c.MaxEntries < len(c.cache)+entries)
+}
+
 // Len 返回容器的大小。
-func (c *Cache) Len() int {
-	return c.ll.Len()
+func (c *TypedCache[K, V]) Len() int {
+	return len(c.cache)
+}
+
+// Bytes 返回当前已经使用的内存。
+func (c *TypedCache[K, V]) Bytes() int64 {
+	return c.useBytes
 }
@@ -1,8 +1,10 @@
 package lru
 
 import (
+	"GoCache/gocache/policy"
 	"reflect"
 	"testing"
+	"time"
 )
 
 type String string
@@ -49,7 +51,7 @@ func TestRemoveOldest(t *testing.T) {
 func TestOnEvicted(t *testing.T) {
 	keys := make([]string, 0)
 	// 注册回调函数，将被淘汰的键值对的key添加到splice中。
-	callback := func(key string, value Value) {
+	callback := func(key string, value Value, reason EvictReason) {
 		keys = append(keys, key)
 	}
 
@@ -68,3 +70,196 @@ func TestOnEvicted(t *testing.T) {
 		t.Fatalf("Call OnEvicted failed, expect keys equals to %s", expect)
 	}
 }
+
+func TestExpire(t *testing.T) {
+	expiredKeys := make([]string, 0)
+	callback := func(key string, value Value, reason EvictReason) {
+		if reason == EvictExpired {
+			expiredKeys = append(expiredKeys, key)
+		}
+	}
+
+	lru := New(int64(0), callback)
+	lru.AddWithExpire("k1", String("v1"), time.Now().Add(-time.Second))
+	lru.AddWithExpire("k2", String("v2"), time.Now().Add(time.Hour))
+	lru.Add("k3", String("v3"))
+
+	// k1已经过期，Get时会被惰性删除。
+	if _, ok := lru.Get("k1"); ok {
+		t.Fatalf("expired k1 should not be returned")
+	}
+	if _, ok := lru.Get("k2"); !ok {
+		t.Fatalf("cache hit k2 failed")
+	}
+	if !reflect.DeepEqual(expiredKeys, []string{"k1"}) || lru.Len() != 2 {
+		t.Fatalf("lazy expiration failed, expired keys: %v", expiredKeys)
+	}
+}
+
+func TestRemoveExpired(t *testing.T) {
+	lru := New(int64(0), nil)
+	past := time.Now().Add(-time.Second)
+	for _, k := range []string{"k1", "k2", "k3"} {
+		lru.AddWithExpire(k, String("v"), past)
+	}
+	lru.Add("k4", String("v4"))
+
+	// 抽查数量大于记录总数时，所有过期的记录都会被删除。
+	if n := lru.RemoveExpired(10); n != 3 || lru.Len() != 1 {
+		t.Fatalf("RemoveExpired removed %d entries, %d left", n, lru.Len())
+	}
+}
+
+func TestNewWithPolicy(t *testing.T) {
+	// Cache的容量仅能存下两个键值对。
+	lru := NewWithPolicy(int64(len("k1v1k2v2")), nil, policy.NewLFU[string]())
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	lru.Get("k1")
+	lru.Get("k1")
+	lru.Get("k2")
+	// LFU策略下，k1访问次数最多，加入k3时会淘汰k2，而不是最久未访问的k1。
+	lru.Add("k3", String("v3"))
+
+	if _, ok := lru.Get("k1"); !ok {
+		t.Fatalf("LFU evicted the most frequently used k1")
+	}
+	if _, ok := lru.Get("k2"); ok || lru.Len() != 2 {
+		t.Fatalf("LFU should evict k2")
+	}
+}
+
+func TestNewCache(t *testing.T) {
+	// 没有size函数时，maxBytes代表最大记录数。
+	lru := NewCache[int, string](2, nil, nil)
+	lru.Add(1, "one")
+	lru.Add(2, "two")
+	lru.Get(1)
+	lru.Add(3, "three")
+	if _, ok := lru.Get(2); ok || lru.Len() != 2 {
+		t.Fatalf("expected 2 to be evicted, len %d", lru.Len())
+	}
+	if v, ok := lru.Get(1); !ok || v != "one" {
+		t.Fatalf("cache hit 1 failed")
+	}
+
+	// 使用size函数统计内存。
+	sized := NewCache[int, []byte](10, func(k int, v []byte) int64 { return int64(len(v)) }, nil)
+	sized.Add(1, make([]byte, 6))
+	sized.Add(2, make([]byte, 6))
+	if _, ok := sized.Get(1); ok || sized.Bytes() != 6 {
+		t.Fatalf("expected 1 to be evicted, bytes %d", sized.Bytes())
+	}
+}
+
+func TestPeek(t *testing.T) {
+	lru := NewCache[string, string](2, nil, nil)
+	lru.Add("k1", "v1")
+	lru.Add("k2", "v2")
+	// Peek不影响淘汰顺序，k1仍然最先被淘汰。
+	if v, ok := lru.Peek("k1"); !ok || v != "v1" {
+		t.Fatalf("peek k1 failed")
+	}
+	lru.Add("k3", "v3")
+	if lru.Contains("k1") || !lru.Contains("k2") {
+		t.Fatalf("expected k1 to be evicted")
+	}
+	if keys := lru.Keys(); !reflect.DeepEqual(keys, []string{"k3", "k2"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+}
+
+func TestResizeClear(t *testing.T) {
+	var evicted []string
+	lru := NewCache[string, string](0, nil, func(key string, value string, reason EvictReason) {
+		evicted = append(evicted, key)
+	})
+	for _, k := range []string{"k1", "k2", "k3", "k4"} {
+		lru.Add(k, k)
+	}
+	if n := lru.Resize(2); n != 2 || !reflect.DeepEqual(evicted, []string{"k1", "k2"}) {
+		t.Fatalf("unexpected eviction %d, %v", n, evicted)
+	}
+
+	values := make(map[string]string)
+	lru.Range(func(key string, value string) bool {
+		values[key] = value
+		return true
+	})
+	if !reflect.DeepEqual(values, map[string]string{"k3": "k3", "k4": "k4"}) {
+		t.Fatalf("unexpected values %v", values)
+	}
+
+	lru.Clear()
+	if lru.Len() != 0 || lru.Bytes() != 0 || len(evicted) != 4 || len(lru.Keys()) != 0 {
+		t.Fatalf("expected empty cache, len %d, evicted %v", lru.Len(), evicted)
+	}
+}
+
+func TestEvictReason(t *testing.T) {
+	reasons := make(map[string]EvictReason)
+	lru := NewCache[string, string](2, nil, func(key string, value string, reason EvictReason) {
+		reasons[key+"="+value] = reason
+	})
+	lru.Add("k1", "v1")
+	lru.Add("k1", "v1'")
+	lru.Add("k2", "v2")
+	lru.Add("k3", "v3")
+	lru.Remove("k2")
+	lru.AddWithExpire("k4", "v4", time.Now().Add(-time.Second))
+	lru.Get("k4")
+	lru.Clear()
+
+	expect := map[string]EvictReason{
+		"k1=v1":  EvictReplaced,
+		"k1=v1'": EvictCapacity,
+		"k2=v2":  EvictRemoved,
+		"k4=v4":  EvictExpired,
+		"k3=v3":  EvictCleared,
+	}
+	if !reflect.DeepEqual(reasons, expect) {
+		t.Fatalf("unexpected reasons %v", reasons)
+	}
+}
+
+func TestMaxEntries(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.MaxEntries = 2
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	lru.Add("k3", String("v3"))
+	if _, ok := lru.Get("k1"); ok || lru.Len() != 2 {
+		t.Fatalf("expected k1 to be evicted, len %d", lru.Len())
+	}
+	if EntryOverhead[string, String]() <= 0 {
+		t.Fatalf("entry overhead should be positive")
+	}
+}
+
+func TestSampleOldest(t *testing.T) {
+	var now int64
+	lru := NewCache[string, string](0, nil, nil)
+	lru.Clock = func() int64 {
+		now++
+		return now
+	}
+	lru.Add("k1", "v1")
+	lru.Add("k2", "v2")
+	lru.Add("k3", "v3")
+	lru.Get("k1")
+	if key, atime, ok := lru.SampleOldest(3); !ok || key != "k2" || atime != 2 {
+		t.Fatalf("expected k2 to be the oldest, got %s, %d", key, atime)
+	}
+	if !lru.Evict("k2") || lru.Contains("k2") {
+		t.Fatalf("evict k2 failed")
+	}
+}
+
+func TestCacheAlias(t *testing.T) {
+	// 泛型之前的API仍然可以使用不带类型参数的*Cache。
+	var c *Cache = New(0, nil)
+	c.Add("key1", String("1234"))
+	if v, ok := c.Get("key1"); !ok || string(v.(String)) != "1234" {
+		t.Fatalf("cache hit key1=1234 failed")
+	}
+}
@@ -0,0 +1,193 @@
+package gocache
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 延迟直方图的桶上限（秒），与Prometheus客户端的默认值一致。
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// histogram 记录延迟分布的直方图，可以被并发地更新。
+type histogram struct {
+	mu     sync.Mutex
+	counts []int64 // counts[i]是落在(latencyBuckets[i-1], latencyBuckets[i]]中的次数，最后一个是大于所有上限的次数。
+	sum    float64
+	count  int64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]int64, len(latencyBuckets)+1)}
+}
+
+// 记录一次耗时d。
+func (h *histogram) observe(d time.Duration) {
+	v := d.Seconds()
+	i := sort.SearchFloat64s(latencyBuckets, v)
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.count++
+	h.mu.Unlock()
+}
+
+// 以Prometheus文本格式输出直方图，桶的计数是累计值。
+func (h *histogram) write(w io.Writer, name, labels string) {
+	h.mu.Lock()
+	counts := append([]int64(nil), h.counts...)
+	sum, count := h.sum, h.count
+	h.mu.Unlock()
+
+	var cumulative int64
+	for i, le := range latencyBuckets {
+		cumulative += counts[i]
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, count)
+	fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, strconv.FormatFloat(sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, count)
+}
+
+// groupMetrics Group的延迟直方图。计数类的指标来自Group.Stats()。
+type groupMetrics struct {
+	loadLatency *histogram // 缓存未命中时，加载（从远程节点或者本地）的耗时。
+
+	mu          sync.Mutex
+	peerLatency map[string]*histogram // 访问每个远程节点的耗时。
+}
+
+func newGroupMetrics() *groupMetrics {
+	return &groupMetrics{
+		loadLatency: newHistogram(),
+		peerLatency: make(map[string]*histogram),
+	}
+}
+
+// 记录一次访问远程节点peer的耗时。
+func (m *groupMetrics) observePeer(peer string, d time.Duration) {
+	m.mu.Lock()
+	h, ok := m.peerLatency[peer]
+	if !ok {
+		h = newHistogram()
+		m.peerLatency[peer] = h
+	}
+	m.mu.Unlock()
+	h.observe(d)
+}
+
+// 返回远程节点的名称，用作指标的标签。实现了fmt.Stringer的PeerGetter（例如httpGetter）使用String()。
+func peerName(peer PeerGetter) string {
+	if s, ok := peer.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", peer)
+}
+
+// MetricsHandler 返回以Prometheus文本格式输出所有Group指标的http.Handler，通常注册在/metrics路径上。
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteMetrics(w)
+	})
+}
+
+// 一个计数类指标的定义。
+type counterMetric struct {
+	name, help string
+	value      func(s *Stats) int64
+}
+
+var counterMetrics = []counterMetric{
+	{"gocache_gets_total", "Number of Get requests.", func(s *Stats) int64 { return s.Gets.Get() }},
+	{"gocache_cache_hits_total", "Number of Get requests served from main or hot cache.", func(s *Stats) int64 { return s.CacheHits.Get() }},
+	{"gocache_cache_misses_total", "Number of Get requests that missed both caches, including negative cache hits and bloom filter rejects.", func(s *Stats) int64 { return s.Gets.Get() - s.CacheHits.Get() }},
+	{"gocache_loads_deduped_total", "Number of loads after singleflight deduplication.", func(s *Stats) int64 { return s.LoadsDeduped.Get() }},
+	{"gocache_peer_loads_total", "Number of successful loads from peers.", func(s *Stats) int64 { return s.PeerLoads.Get() }},
+	{"gocache_peer_errors_total", "Number of failed loads from peers.", func(s *Stats) int64 { return s.PeerErrors.Get() }},
+	{"gocache_local_loads_total", "Number of successful loads from the Getter.", func(s *Stats) int64 { return s.LocalLoads.Get() }},
+	{"gocache_local_load_errors_total", "Number of failed loads from the Getter.", func(s *Stats) int64 { return s.LocalLoadErrs.Get() }},
+	{"gocache_negative_hits_total", "Number of Get requests answered as not found by the negative cache.", func(s *Stats) int64 { return s.NegativeHits.Get() }},
+	{"gocache_bloom_rejects_total", "Number of Get requests rejected by the bloom filter.", func(s *Stats) int64 { return s.BloomRejects.Get() }},
+	{"gocache_stale_hits_total", "Number of Get requests served with a stale value.", func(s *Stats) int64 { return s.StaleHits.Get() }},
+	{"gocache_refreshes_total", "Number of background refreshes started.", func(s *Stats) int64 { return s.Refreshes.Get() }},
+	{"gocache_capacity_shrinks_total", "Number of times the cache capacity was shrunk under memory pressure.", func(s *Stats) int64 { return s.CapacityShrinks.Get() }},
+	{"gocache_capacity_grows_total", "Number of times the cache capacity was grown back after memory pressure dropped.", func(s *Stats) int64 { return s.CapacityGrows.Get() }},
+}
+
+// WriteMetrics 以Prometheus文本格式将所有Group的指标写入w。
+func WriteMetrics(w io.Writer) {
+	mu.RLock()
+	all := make([]*Group, 0, len(groups))
+	for _, g := range groups {
+		all = append(all, g)
+	}
+	mu.RUnlock()
+	sort.Slice(all, func(i, j int) bool { return all[i].name < all[j].name })
+
+	stats := make([]Stats, len(all))
+	for i, g := range all {
+		stats[i] = g.Stats()
+	}
+
+	for _, m := range counterMetrics {
+		writeHeader(w, m.name, m.help, "counter")
+		for i, g := range all {
+			fmt.Fprintf(w, "%s{group=\"%s\"} %d\n", m.name, escapeLabel(g.name), m.value(&stats[i]))
+		}
+	}
+
+	cacheMetrics := []struct {
+		name, help, typ string
+		value           func(s CacheStats) int64
+	}{
+		{"gocache_cache_bytes", "Bytes used by the cache.", "gauge", func(s CacheStats) int64 { return s.Bytes }},
+		{"gocache_cache_items", "Number of items in the cache.", "gauge", func(s CacheStats) int64 { return s.Items }},
+		{"gocache_cache_evictions_total", "Number of items evicted or expired from the cache.", "counter", func(s CacheStats) int64 { return s.Evictions }},
+	}
+	for _, m := range cacheMetrics {
+		writeHeader(w, m.name, m.help, m.typ)
+		for i, g := range all {
+			fmt.Fprintf(w, "%s{group=\"%s\",cache=\"main\"} %d\n", m.name, escapeLabel(g.name), m.value(stats[i].MainCache))
+			fmt.Fprintf(w, "%s{group=\"%s\",cache=\"hot\"} %d\n", m.name, escapeLabel(g.name), m.value(stats[i].HotCache))
+		}
+	}
+
+	writeHeader(w, "gocache_load_duration_seconds", "Latency of loads from peers or the Getter after a cache miss.", "histogram")
+	for _, g := range all {
+		g.metrics.loadLatency.write(w, "gocache_load_duration_seconds", fmt.Sprintf("group=\"%s\"", escapeLabel(g.name)))
+	}
+
+	writeHeader(w, "gocache_peer_request_duration_seconds", "Latency of Get requests to peers.", "histogram")
+	for _, g := range all {
+		g.metrics.mu.Lock()
+		latency := make(map[string]*histogram, len(g.metrics.peerLatency))
+		peers := make([]string, 0, len(g.metrics.peerLatency))
+		for peer, h := range g.metrics.peerLatency {
+			latency[peer] = h
+			peers = append(peers, peer)
+		}
+		g.metrics.mu.Unlock()
+		sort.Strings(peers)
+		for _, peer := range peers {
+			latency[peer].write(w, "gocache_peer_request_duration_seconds",
+				fmt.Sprintf("group=\"%s\",peer=\"%s\"", escapeLabel(g.name), escapeLabel(peer)))
+		}
+	}
+}
+
+func writeHeader(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// 转义标签值中的反斜杠、双引号和换行符。
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
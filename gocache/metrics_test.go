@@ -0,0 +1,36 @@
+package gocache
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	group := NewGroup("metrics", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}), WithHotCache(1<<10, 1))
+	group.RegisterPeers(fakePicker{peer: &fakePeer{}})
+	group.Get(context.Background(), "Tom")
+	group.Get(context.Background(), "Tom")
+
+	var b strings.Builder
+	WriteMetrics(&b)
+	out := b.String()
+	for _, line := range []string{
+		"# TYPE gocache_gets_total counter",
+		`gocache_gets_total{group="metrics"} 2`,
+		`gocache_cache_hits_total{group="metrics"} 1`,
+		`gocache_cache_misses_total{group="metrics"} 1`,
+		`gocache_peer_loads_total{group="metrics"} 1`,
+		`gocache_cache_items{group="metrics",cache="hot"} 1`,
+		"# TYPE gocache_load_duration_seconds histogram",
+		`gocache_load_duration_seconds_count{group="metrics"} 1`,
+		`gocache_peer_request_duration_seconds_count{group="metrics",peer="*gocache.fakePeer"} 1`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("metrics output missing %q", line)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package gocache
+
+import (
+	"GoCache/gocache/singleflight"
+	pb "GoCache/gocachepb"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// BatchGetter 可选接口。Getter同时实现了BatchGetter时，GetMulti对本地未命中的key只调用一次GetMulti，而不是逐个调用Get，
+// 例如使用一条SELECT ... WHERE id IN (...)查询数据库。返回的map中不包含的key代表数据不存在。
+// 批量加载与Get共用singleflight，正在被其他请求加载的key不会再次出现在keys中。
+type BatchGetter interface {
+	GetMulti(ctx context.Context, keys []string) (map[string][]byte, error)
+}
+
+// GetMulti 批量获取多个key的缓存值，返回的map中只包含数据存在的key。
+// 本地命中的key直接返回；未命中的key按照PickPeer选出的节点分组，每个远程节点只发送一次批量请求；
+// 由本节点负责（或者远程节点请求失败，见WithPeerFallback）的key从本地加载，Getter实现了BatchGetter时一次性加载。
+// 部分key加载失败时，仍然返回其他key的值，以及遇到的第一个错误。数据不存在（ErrNotFound）不算作错误。
+func (g *Group) GetMulti(ctx context.Context, keys []string) (map[string]ByteView, error) {
+	values, _, err := g.getMulti(ctx, keys)
+	return values, err
+}
+
+// 返回数据存在的key的值，数据不存在的key，以及遇到的第一个错误。
+func (g *Group) getMulti(ctx context.Context, keys []string) (map[string]ByteView, []string, error) {
+	var (
+		values  = make(map[string]ByteView, len(keys))
+		stale   = make(map[string]ByteView) // 已经硬过期的旧值，加载失败时返回。
+		seen    = make(map[string]bool, len(keys))
+		misses  []string
+		missing []string
+	)
+	for _, key := range keys {
+		if key == "" {
+			return nil, nil, fmt.Errorf("key is required")
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		g.stats.Gets.Add(1)
+		v, fresh, ok := g.lookup(key)
+		switch {
+		case ok && fresh:
+			values[key] = v
+		case ok:
+			stale[key] = v
+			misses = append(misses, key)
+		case g.knownMissing(key):
+			missing = append(missing, key)
+		default:
+			misses = append(misses, key)
+		}
+	}
+	if len(misses) == 0 {
+		return values, missing, nil
+	}
+	g.stats.Loads.Add(int64(len(misses)))
+
+	var firstErr error
+	// 加载key失败，err为ErrNotFound代表数据不存在。
+	fail := func(key string, err error) {
+		if v, ok := stale[key]; ok && g.useStale(ctx, key, err) {
+			values[key] = v
+			return
+		}
+		if errors.Is(err, ErrNotFound) {
+			missing = append(missing, key)
+			return
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	local := g.getMultiFromPeers(ctx, misses, values, fail)
+	if len(local) > 0 {
+		if batch, ok := g.getter.(BatchGetter); ok {
+			g.getMultiLocally(ctx, batch, local, values, fail)
+		} else {
+			for _, key := range local {
+				// 和Get一样经过singleflight去重。
+				viewi, err, _ := g.loader.Do(ctx, key, func() (interface{}, error) {
+					ctx, cancel := g.loadContext(ctx)
+					defer cancel()
+					g.stats.LoadsDeduped.Add(1)
+					return g.loadLocally(ctx, key)
+				})
+				if err != nil {
+					fail(key, err)
+					continue
+				}
+				values[key] = viewi.(ByteView)
+			}
+		}
+	}
+	return values, missing, firstErr
+}
+
+// 将keys按照负责的远程节点分组，并发地向每个节点发送一次批量请求，结果写入values或者交给fail处理。
+// 返回由本节点负责，或者需要回退到本地加载的key。其他节点转发来的请求只在本节点加载。
+func (g *Group) getMultiFromPeers(ctx context.Context, keys []string, values map[string]ByteView, fail func(string, error)) []string {
+	if g.peers == nil || isPeerRequest(ctx) {
+		return keys
+	}
+	var local []string
+	// 远程节点获取key失败时，按照WithPeerFallback的设置处理。
+	fallback := func(key string, peerErr error) {
+		if g.peerPolicy.Fallback == FallbackLocal && g.peerPolicy.Retries == 0 {
+			local = append(local, key)
+			return
+		}
+		viewi, err, _ := g.loader.Do(ctx, key, func() (interface{}, error) {
+			ctx, cancel := g.loadContext(ctx)
+			defer cancel()
+			return g.loadFallback(ctx, key, peerErr)
+		})
+		if err != nil {
+			fail(key, err)
+			return
+		}
+		values[key] = viewi.(ByteView)
+	}
+	byPeer := make(map[PeerGetter][]string)
+	for _, key := range keys {
+		if peer, ok := g.peers.PickPeer(key); ok {
+			byPeer[peer] = append(byPeer[peer], key)
+		} else {
+			local = append(local, key)
+		}
+	}
+
+	type result struct {
+		peer PeerGetter
+		keys []string
+		res  *pb.MultiResponse
+		err  error
+	}
+	results := make(chan result, len(byPeer))
+	var wg sync.WaitGroup
+	for peer, keys := range byPeer {
+		wg.Add(1)
+		go func(peer PeerGetter, keys []string) {
+			defer wg.Done()
+			res, err := g.getMultiFromPeer(ctx, peer, keys)
+			results <- result{peer, keys, res, err}
+		}(peer, keys)
+	}
+	wg.Wait()
+	close(results)
+
+	for r := range results {
+		g.stats.LoadsDeduped.Add(int64(len(r.keys)))
+		if r.err != nil {
+			g.stats.PeerErrors.Add(int64(len(r.keys)))
+			log.Println("[GoCache] Failed to get multi from peer", r.err)
+			for _, key := range r.keys {
+				fallback(key, &PeerError{Peer: peerName(r.peer), Key: key, Err: r.err})
+			}
+			continue
+		}
+		notFound := make(map[string]bool, len(r.res.GetMissing()))
+		for _, key := range r.res.GetMissing() {
+			notFound[key] = true
+		}
+		for _, key := range r.keys {
+			b, ok := r.res.GetValues()[key]
+			switch {
+			case ok:
+				g.stats.PeerLoads.Add(1)
+				value := ByteView{b: b}
+				if g.hotSampleRate > 0 && rand.Intn(g.hotSampleRate) == 0 {
+					g.hotCache.add(key, value, g.expire())
+				}
+				values[key] = value
+			case notFound[key]:
+				// 负责该key的节点已经确认数据不存在，不需要再回退到本地加载。
+				g.stats.PeerLoads.Add(1)
+				g.populateNegativeCache(key)
+				fail(key, fmt.Errorf("%w: %s", ErrNotFound, key))
+			default:
+				// 远程节点加载该key失败。
+				g.stats.PeerErrors.Add(1)
+				fallback(key, &PeerError{Peer: peerName(r.peer), Key: key, Err: errors.New("peer failed to load key")})
+			}
+		}
+	}
+	return local
+}
+
+// 使用BatchGetter一次性从本地加载keys，结果写入values或者交给fail处理。
+// 和Get一样经过singleflight去重：每个key登记为正在进行的请求，已经有其他调用方在加载的key等待那次加载的结果，
+// 只有本次登记的key进入批量加载；批量加载期间的Get同样等待批量加载的结果。
+func (g *Group) getMultiLocally(ctx context.Context, batch BatchGetter, keys []string, values map[string]ByteView, fail func(string, error)) {
+	var (
+		res      map[string][]byte
+		batchErr = errors.New("gocache: batch load did not complete")
+		done     = make(chan struct{})
+		own      []string
+		results  = make(map[string]<-chan singleflight.Result, len(keys))
+	)
+	for _, key := range keys {
+		key := key
+		ch, owner := g.loader.DoChanOwner(key, func() (interface{}, error) {
+			<-done
+			return g.batchResult(key, res, batchErr)
+		})
+		results[key] = ch
+		if owner {
+			own = append(own, key)
+		}
+	}
+
+	func() {
+		// BatchGetter发生panic时，仍然唤醒等待批量加载的请求。
+		defer close(done)
+		if len(own) == 0 {
+			return
+		}
+		g.stats.LoadsDeduped.Add(int64(len(own)))
+		loadCtx, cancel := g.loadContext(ctx)
+		defer cancel()
+		start := time.Now()
+		res, batchErr = batch.GetMulti(loadCtx, own)
+		g.metrics.loadLatency.observe(time.Since(start))
+	}()
+
+	for _, key := range keys {
+		select {
+		case r := <-results[key]:
+			if r.Err != nil {
+				fail(key, r.Err)
+				continue
+			}
+			values[key] = r.Val.(ByteView)
+		case <-ctx.Done():
+			fail(key, ctx.Err())
+		}
+	}
+}
+
+// 处理批量加载中key的结果，更新统计数据和缓存。
+func (g *Group) batchResult(key string, res map[string][]byte, err error) (interface{}, error) {
+	if err != nil {
+		g.stats.LocalLoadErrs.Add(1)
+		return nil, err
+	}
+	b, ok := res[key]
+	if !ok {
+		g.stats.LocalLoadErrs.Add(1)
+		g.populateNegativeCache(key)
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	g.stats.LocalLoads.Add(1)
+	value := ByteView{b: cloneBytes(b)}
+	g.populateCache(key, value)
+	return value, nil
+}
+
+// 访问远程节点，批量获取keys的缓存值。
+func (g *Group) getMultiFromPeer(ctx context.Context, peer PeerGetter, keys []string) (*pb.MultiResponse, error) {
+	req := &pb.MultiRequest{
+		Group: g.name,
+		Keys:  keys,
+	}
+	res := &pb.MultiResponse{}
+	start := time.Now()
+	err := peer.GetMulti(ctx, req, res)
+	g.metrics.observePeer(peerName(peer), time.Since(start))
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
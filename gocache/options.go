@@ -0,0 +1,160 @@
+package gocache
+
+import (
+	"GoCache/gocache/policy"
+	"GoCache/gocache/slab"
+	"time"
+)
+
+// GroupOption 用于在NewGroup时配置Group的可选参数。
+type GroupOption func(*Group)
+
+// WithTTL 设置Group中缓存项的默认过期时间，ttl <= 0代表永不过期。
+// 过期的缓存项在被访问时惰性删除，同时由后台协程定期清理。
+func WithTTL(ttl time.Duration) GroupOption {
+	return func(g *Group) {
+		g.ttl = ttl
+	}
+}
+
+// WithLoadTimeout 设置缓存未命中时加载的超时时间，默认30秒，timeout <= 0代表不限制。
+// 同一个key的并发请求共享一次加载，加载不会因为某个调用方取消而中断，每个调用方在自己的ctx结束时放弃等待。
+func WithLoadTimeout(timeout time.Duration) GroupOption {
+	return func(g *Group) {
+		g.loadTimeout = timeout
+	}
+}
+
+// WithShards 设置mainCache的分片数量，缓存容量由各个分片平分。
+// 分片越多锁竞争越小，但是单个分片的容量也越小。n <= 0代表根据缓存容量自动选择。
+func WithShards(n int) GroupOption {
+	return func(g *Group) {
+		g.shards = n
+	}
+}
+
+// WithMaxEntries 限制mainCache的最大缓存项数量，由各个分片平分。与缓存容量同时生效，任意一个超出时淘汰旧值。
+// 适用于缓存值很小、数量很多，需要直接控制缓存项数量的场景。n <= 0代表不限制。
+// 使用WithShards设置的分片数量大于n时，每个分片至少保留1个缓存项，总数量最多为分片数量。
+func WithMaxEntries(n int) GroupOption {
+	return func(g *Group) {
+		if n > 0 {
+			g.maxEntries = n
+		}
+	}
+}
+
+// WithPolicy 设置mainCache的淘汰策略，例如WithPolicy(policy.NewARC[string])。默认使用LRU。
+// 每个分片会调用newPolicy创建独立的策略实例。
+func WithPolicy(newPolicy func() policy.Policy[string]) GroupOption {
+	return func(g *Group) {
+		g.newPolicy = newPolicy
+	}
+}
+
+// WithSlabStorage 将mainCache和hotCache的缓存项紧凑地保存在预先分配的大块[]byte（slab）中，
+// 索引中不包含指针，缓存项很多时可以显著减少GC扫描的时间。slabSize是每块slab的大小，<= 0时使用slab.DefaultSlabSize。
+// 代价是每次命中都需要复制缓存值，按照写入顺序（FIFO）淘汰，WithPolicy不再生效。容量为0（不限制）的缓存仍然使用lru。
+func WithSlabStorage(slabSize int64) GroupOption {
+	return func(g *Group) {
+		if slabSize <= 0 {
+			slabSize = slab.DefaultSlabSize
+		}
+		g.slabSize = slabSize
+	}
+}
+
+// WithHotCache 设置hotCache的容量和采样率，从远程节点获取的值有1/sampleRate的概率被添加到hotCache中。
+// 默认容量是mainCache的1/8（不小于8KB），采样率是10。sampleRate <= 0代表不使用hotCache。
+func WithHotCache(cacheBytes int64, sampleRate int) GroupOption {
+	return func(g *Group) {
+		g.hotCacheBytes = cacheBytes
+		g.hotSampleRate = sampleRate
+	}
+}
+
+// WithNegativeTTL 开启负缓存：Getter返回ErrNotFound时，在ttl内记住该key不存在，不再访问数据库，避免缓存穿透。
+// 其他错误（例如数据库超时）不会被缓存。默认不开启。
+func WithNegativeTTL(ttl time.Duration) GroupOption {
+	return func(g *Group) {
+		g.negativeTTL = ttl
+	}
+}
+
+// WithBloomFilter 开启布隆过滤器：缓存未命中时，如果布隆过滤器确认key一定不存在，直接返回ErrNotFound，
+// 不再调用回调函数，防止攻击者使用随机key造成缓存穿透。
+// expectedItems是预计的key的数量，fpRate是期望的误判率，source用于加载所有存在的key。
+// 需要调用Group.LoadBloomFilter加载过滤器，Set写入的key会被自动加入过滤器。
+func WithBloomFilter(expectedItems int, fpRate float64, source KeySource) GroupOption {
+	return func(g *Group) {
+		g.bloomConfig = &bloomConfig{
+			expectedItems: expectedItems,
+			fpRate:        fpRate,
+			source:        source,
+		}
+	}
+}
+
+// WithStaleWhileRevalidate 开启stale-while-revalidate，需要同时使用WithTTL。ttl成为软过期时间：
+// 软过期后的staleTTL内，Get直接返回旧值，同时在后台刷新（每个key只有一个刷新，经过singleflight去重，超时时间为staleTTL）；
+// 超过staleTTL（硬过期）后，调用方需要等待加载，加载失败时仍然返回旧值，直到软过期后maxStale。
+// 数据源返回ErrNotFound时旧值会被删除。
+func WithStaleWhileRevalidate(staleTTL, maxStale time.Duration) GroupOption {
+	return func(g *Group) {
+		g.staleTTL = staleTTL
+		g.maxStale = maxStale
+	}
+}
+
+// WithEvictionListener 注册缓存项被移除时的回调函数，与Group.AddEvictionListener相同。
+func WithEvictionListener(fn EvictionListener) GroupOption {
+	return func(g *Group) {
+		g.AddEvictionListener(fn)
+	}
+}
+
+// WithMemoryPool 将mainCache加入多个Group共享的内存池pool。weight是使用PoolWeighted策略时的权重（<= 0时为1），
+// reserve是内存池淘汰时至少为该Group保留的内存。cacheBytes仍然是该Group单独的上限，设置为0代表只受内存池的限制。
+// 内存池只统计mainCache，cacheBytes为0时hotCache和negCache的默认容量按照内存池创建时的上限计算。
+func WithMemoryPool(pool *MemoryPool, weight int, reserve int64) GroupOption {
+	return func(g *Group) {
+		if weight <= 0 {
+			weight = 1
+		}
+		g.poolConfig = &poolConfig{pool: pool, weight: int64(weight), reserve: reserve}
+	}
+}
+
+// WithCapacityController 将mainCache和hotCache交给controller，根据Go运行时的内存压力自动缩小和恢复容量。
+// 多个Group可以共用同一个controller，按照相同的比例调整。
+func WithCapacityController(controller *CapacityController) GroupOption {
+	return func(g *Group) {
+		g.capacity = controller
+	}
+}
+
+// WithPeerFallback 设置从负责key的远程节点获取失败（数据不存在除外）时的处理方式，默认是FallbackLocal。
+// 使用FallbackFail、FallbackRetryOwner或者FallbackNextPeer时，非负责节点不会为远程节点负责的key调用Getter，
+// 整个集群对每个key只加载一次。attempts是FallbackRetryOwner的重试次数，或者FallbackNextPeer尝试的后继节点数，<= 0时为1。
+// 需要退避等待时使用WithPeerPolicy。
+func WithPeerFallback(fallback PeerFallback, attempts int) GroupOption {
+	if attempts <= 0 {
+		attempts = 1
+	}
+	policy := PeerPolicy{Fallback: fallback}
+	switch fallback {
+	case FallbackRetryOwner:
+		policy.Retries = attempts
+	case FallbackNextPeer:
+		policy.NextReplicas = attempts
+	}
+	return WithPeerPolicy(policy)
+}
+
+// WithPeerPolicy 设置从负责key的远程节点获取失败时的重试、退避和回退策略，见PeerPolicy。
+// 远程节点的错误以*PeerError返回。
+func WithPeerPolicy(policy PeerPolicy) GroupOption {
+	return func(g *Group) {
+		g.peerPolicy = policy
+	}
+}
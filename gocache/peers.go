@@ -1,17 +1,136 @@
 package gocache
 
-import pb "GoCache/gocachepb"
+import (
+	pb "GoCache/gocachepb"
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
 
 // PeerPicker is the interface that must be implemented to locate
 // the peer that owns a specific key.
 type PeerPicker interface {
 	// PickPeer 根据传入的key选择相应节点的PeerGetter。
 	PickPeer(key string) (peer PeerGetter, ok bool)
+	// GetAll 返回除本节点以外所有节点的PeerGetter，用于广播删除。
+	GetAll() []PeerGetter
 }
 
 // PeerGetter is the interface that must be implemented by a peer.
 type PeerGetter interface {
-	// Get 从对应的group中查询缓存值。相当于HTTP客户端。
+	// Get 从对应的group中查询缓存值。相当于HTTP客户端。ctx结束时请求被取消。
 	// Get(group string, key string) ([]byte, error)
-	Get(in *pb.Request, out *pb.Response) error
+	Get(ctx context.Context, in *pb.Request, out *pb.Response) error
+	// Set 在远程节点对应的group中写入缓存值。
+	Set(ctx context.Context, in *pb.SetRequest, out *pb.Response) error
+	// Remove 在远程节点对应的group中删除缓存值（包括hotCache中的副本）。
+	Remove(ctx context.Context, in *pb.Request, out *pb.Response) error
+	// GetMulti 在一次请求中查询多个key的缓存值。数据不存在的key放在out.Missing中，加载失败的key不出现在out中。
+	GetMulti(ctx context.Context, in *pb.MultiRequest, out *pb.MultiResponse) error
+}
+
+// ReplicaPicker 可选接口。PeerPicker同时实现了ReplicaPicker时，WithPeerFallback(FallbackNextPeer, n)
+// 可以在负责key的节点不可用时，按照哈希环的顺序依次尝试后继节点。
+type ReplicaPicker interface {
+	// PickReplicas 按照哈希环顺时针的顺序返回负责key的前n个不同节点，第一个是PickPeer选出的节点。本节点用nil表示。
+	PickReplicas(key string, n int) []PeerGetter
+}
+
+// PeerFallback 从负责key的远程节点获取失败（数据不存在除外），并且重试完之后的处理方式。
+type PeerFallback int
+
+const (
+	// FallbackLocal 回退到本地加载，默认值。远程节点短暂不可用时，每个节点都会各自调用Getter。
+	FallbackLocal PeerFallback = iota
+	// FallbackFail 直接返回错误，只有负责key的节点会调用Getter。
+	FallbackFail
+	// FallbackRetryOwner 重新访问负责key的节点，仍然失败时返回错误。重试完之后与FallbackFail相同。
+	FallbackRetryOwner
+	// FallbackNextPeer 按照哈希环的顺序访问后继节点，后继节点是本节点时在本地加载。所有节点选出的后继节点相同，
+	// 所以每个key仍然只有一个节点调用Getter。PeerPicker需要实现ReplicaPicker，否则与FallbackFail相同。
+	FallbackNextPeer
+)
+
+// PeerPolicy 从负责key的远程节点获取失败（数据不存在除外）时的策略：先重试负责key的节点Retries次，
+// 每次重试之前按照指数退避等待；仍然失败时按照Fallback处理。零值代表不重试，直接回退到本地加载。
+// 快速失败使用PeerPolicy{Fallback: FallbackFail}。
+type PeerPolicy struct {
+	Fallback PeerFallback
+	// 重试负责key的节点的次数。
+	Retries int
+	// FallbackNextPeer依次尝试的后继节点数，每个后继节点只访问一次。<= 0时为1。
+	NextReplicas int
+	// 第一次重试之前等待的时间，之后每次翻倍，最多MaxBackoff（<= 0代表不限制）。Backoff <= 0代表立即重试。
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// 随机减少等待时间的比例，取值[0, 1]，避免多个节点同时重试。例如0.5代表实际等待时间在[d/2, d]之间。
+	Jitter float64
+}
+
+// 返回第attempt次（从0开始）重试之前等待的时间。
+func (p PeerPolicy) backoff(attempt int) time.Duration {
+	if p.Backoff <= 0 {
+		return 0
+	}
+	d := p.Backoff
+	for i := 0; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if jitter := p.Jitter; jitter > 0 {
+		if jitter > 1 {
+			jitter = 1
+		}
+		d -= time.Duration(rand.Float64() * jitter * float64(d))
+	}
+	return d
+}
+
+// 等待d，ctx先结束时返回ctx.Err()。
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PeerError 从远程节点获取key失败的错误，Peer是节点的名称（实现了fmt.Stringer时是String()，例如节点的地址）。
+// 调用方可以使用errors.As取出，根据失败的节点和原因做出决定。
+type PeerError struct {
+	Peer string
+	Key  string
+	Err  error
+}
+
+func (e *PeerError) Error() string {
+	return fmt.Sprintf("get %s from peer %s: %v", e.Key, e.Peer, e.Err)
+}
+
+// Unwrap 返回失败的原因，例如context.DeadlineExceeded。
+func (e *PeerError) Unwrap() error {
+	return e.Err
+}
+
+type peerRequestKey struct{}
+
+// NewPeerContext 标记ctx属于其他节点转发来的请求。带有该标记的Get和GetMulti只在本节点加载，不再转发给其他节点，
+// 避免节点之间对key的归属看法不一致（或者使用FallbackNextPeer）时循环转发。自定义的节点间通信需要在服务端调用。
+func NewPeerContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, peerRequestKey{}, true)
+}
+
+// 判断ctx是否属于其他节点转发来的请求。
+func isPeerRequest(ctx context.Context) bool {
+	fromPeer, _ := ctx.Value(peerRequestKey{}).(bool)
+	return fromPeer
 }
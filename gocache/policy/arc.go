@@ -0,0 +1,100 @@
+package policy
+
+// ARC 自适应替换缓存（Adaptive Replacement Cache）淘汰策略。
+// t1保存只被访问过一次的key，t2保存被访问过多次的key，b1、b2分别是从t1、t2淘汰的key的幽灵列表（只保存key）。
+// 新加入的key命中幽灵列表时，说明对应的列表分配得太小了，通过调整t1的目标大小p在"最近"和"频繁"之间自适应，
+// 一次性的批量扫描只会冲刷t1，不会影响t2中的热点数据。
+// 缓存的容量由lru.Cache按内存控制，这里以当前缓存中key的数量作为容量c，幽灵列表的长度不超过c。
+type ARC[K comparable] struct {
+	p              int // t1的目标大小。
+	t1, t2, b1, b2 *LRU[K]
+}
+
+// NewARC 实例化ARC策略。
+func NewARC[K comparable]() Policy[K] {
+	return &ARC[K]{
+		t1: newLRU[K](),
+		t2: newLRU[K](),
+		b1: newLRU[K](),
+		b2: newLRU[K](),
+	}
+}
+
+func (p *ARC[K]) Add(key K) {
+	switch {
+	case p.t1.contains(key) || p.t2.contains(key):
+		p.Access(key)
+	case p.b1.contains(key):
+		// 命中b1，说明t1太小，增大p。
+		p.p = min(p.p+max(p.b2.Len()/p.b1.Len(), 1), p.Len()+1)
+		p.b1.Remove(key)
+		p.t2.Add(key)
+	case p.b2.contains(key):
+		// 命中b2，说明t2太小，减小p。
+		p.p = max(p.p-max(p.b1.Len()/p.b2.Len(), 1), 0)
+		p.b2.Remove(key)
+		p.t2.Add(key)
+	default:
+		p.t1.Add(key)
+	}
+}
+
+func (p *ARC[K]) Access(key K) {
+	if p.t1.contains(key) {
+		// 第二次访问，从t1提升到t2。
+		p.t1.Remove(key)
+		p.t2.Add(key)
+		return
+	}
+	p.t2.Access(key)
+}
+
+func (p *ARC[K]) Remove(key K) {
+	p.t1.Remove(key)
+	p.t2.Remove(key)
+}
+
+func (p *ARC[K]) Victim() (K, bool) {
+	var key K
+	if p.t1.Len() > 0 && (p.t1.Len() > p.p || p.t2.Len() == 0) {
+		key, _ = p.t1.Victim()
+		p.b1.Add(key)
+	} else if p.t2.Len() > 0 {
+		key, _ = p.t2.Victim()
+		p.b2.Add(key)
+	} else {
+		return key, false
+	}
+	// 限制幽灵列表的长度。
+	c := max(p.Len(), 1)
+	for p.b1.Len() > c {
+		p.b1.Victim()
+	}
+	for p.b2.Len() > c {
+		p.b2.Victim()
+	}
+	return key, true
+}
+
+func (p *ARC[K]) Len() int {
+	return p.t1.Len() + p.t2.Len()
+}
+
+// t2中的key被访问过多次，通常比t1中的key更晚被淘汰。
+func (p *ARC[K]) Keys() []K {
+	return append(p.t2.Keys(), p.t1.Keys()...)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,111 @@
+package policy
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// LFU 最不经常使用（Least Frequently Used）淘汰策略，淘汰访问次数最少的key，次数相同时淘汰最久未访问的key。
+// 使用最小堆维护访问次数，Add/Access/Victim的时间复杂度都是O(log n)。
+type LFU[K comparable] struct {
+	h     lfuHeap[K]
+	items map[K]*lfuItem[K]
+	tick  uint64 // 逻辑时钟，用于在访问次数相同时比较新旧。
+}
+
+type lfuItem[K comparable] struct {
+	key   K
+	freq  uint64
+	tick  uint64
+	index int // 在堆中的下标。
+}
+
+// NewLFU 实例化LFU策略。
+func NewLFU[K comparable]() Policy[K] {
+	return &LFU[K]{items: make(map[K]*lfuItem[K])}
+}
+
+func (p *LFU[K]) Add(key K) {
+	if _, ok := p.items[key]; ok {
+		p.Access(key)
+		return
+	}
+	p.tick++
+	item := &lfuItem[K]{key: key, freq: 1, tick: p.tick}
+	p.items[key] = item
+	heap.Push(&p.h, item)
+}
+
+func (p *LFU[K]) Access(key K) {
+	if item, ok := p.items[key]; ok {
+		p.tick++
+		item.freq++
+		item.tick = p.tick
+		heap.Fix(&p.h, item.index)
+	}
+}
+
+func (p *LFU[K]) Remove(key K) {
+	if item, ok := p.items[key]; ok {
+		heap.Remove(&p.h, item.index)
+		delete(p.items, key)
+	}
+}
+
+func (p *LFU[K]) Victim() (K, bool) {
+	if len(p.h) == 0 {
+		var zero K
+		return zero, false
+	}
+	item := heap.Pop(&p.h).(*lfuItem[K])
+	delete(p.items, item.key)
+	return item.key, true
+}
+
+func (p *LFU[K]) Len() int {
+	return len(p.h)
+}
+
+func (p *LFU[K]) Keys() []K {
+	items := append(lfuHeap[K](nil), p.h...)
+	// 按照淘汰顺序的逆序排列。
+	sort.Slice(items, func(i, j int) bool { return items.Less(j, i) })
+	keys := make([]K, len(items))
+	for i, item := range items {
+		keys[i] = item.key
+	}
+	return keys
+}
+
+// lfuHeap 实现heap.Interface，堆顶是访问次数最少、最久未访问的key。
+type lfuHeap[K comparable] []*lfuItem[K]
+
+func (h lfuHeap[K]) Len() int { return len(h) }
+
+func (h lfuHeap[K]) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].tick < h[j].tick
+}
+
+func (h lfuHeap[K]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap[K]) Push(x interface{}) {
+	item := x.(*lfuItem[K])
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *lfuHeap[K]) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
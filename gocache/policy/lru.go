@@ -0,0 +1,93 @@
+package policy
+
+import "container/list"
+
+// LRU 最近最少使用（Least Recently Used）淘汰策略，默认策略。
+// 使用双向链表，约定front为队尾（最近访问），back为队头（最久未访问）。
+type LRU[K comparable] struct {
+	ll    *list.List
+	items map[K]*list.Element
+}
+
+// NewLRU 实例化LRU策略。
+func NewLRU[K comparable]() Policy[K] {
+	return newLRU[K]()
+}
+
+func newLRU[K comparable]() *LRU[K] {
+	return &LRU[K]{
+		ll:    list.New(),
+		items: make(map[K]*list.Element),
+	}
+}
+
+func (p *LRU[K]) Add(key K) {
+	if ele, ok := p.items[key]; ok {
+		p.ll.MoveToFront(ele)
+		return
+	}
+	p.items[key] = p.ll.PushFront(key)
+}
+
+func (p *LRU[K]) Access(key K) {
+	if ele, ok := p.items[key]; ok {
+		p.ll.MoveToFront(ele)
+	}
+}
+
+func (p *LRU[K]) Remove(key K) {
+	if ele, ok := p.items[key]; ok {
+		p.ll.Remove(ele)
+		delete(p.items, key)
+	}
+}
+
+func (p *LRU[K]) Victim() (K, bool) {
+	ele := p.ll.Back()
+	if ele == nil {
+		var zero K
+		return zero, false
+	}
+	key := ele.Value.(K)
+	p.ll.Remove(ele)
+	delete(p.items, key)
+	return key, true
+}
+
+func (p *LRU[K]) Len() int {
+	return p.ll.Len()
+}
+
+func (p *LRU[K]) Keys() []K {
+	keys := make([]K, 0, p.ll.Len())
+	for ele := p.ll.Front(); ele != nil; ele = ele.Next() {
+		keys = append(keys, ele.Value.(K))
+	}
+	return keys
+}
+
+// 判断key是否在链表中。
+func (p *LRU[K]) contains(key K) bool {
+	_, ok := p.items[key]
+	return ok
+}
+
+// 返回队头（最久未访问）的key，但不删除。
+func (p *LRU[K]) back() (K, bool) {
+	ele := p.ll.Back()
+	if ele == nil {
+		var zero K
+		return zero, false
+	}
+	return ele.Value.(K), true
+}
+
+// 返回队尾（最近访问）的key，但不删除。
+func (p *LRU[K]) front() (K, bool) {
+	ele := p.ll.Front()
+	if ele == nil {
+		var zero K
+		return zero, false
+	}
+	return ele.Value.(K), true
+}
@@ -0,0 +1,19 @@
+package policy
+
+// Policy 缓存淘汰策略。策略只负责维护key的淘汰顺序，缓存值的存储、内存统计和过期时间由lru.Cache负责。
+// 策略在并发访问下线程不安全，由调用方加锁。
+type Policy[K comparable] interface {
+	// Add 通知策略有新的key加入缓存。
+	Add(key K)
+	// Access 通知策略key被访问（命中缓存或者值被更新）。
+	Access(key K)
+	// Remove 通知策略key被缓存主动删除（例如过期），不属于策略选出的淘汰。
+	Remove(key K)
+	// Victim 选出下一个应当被淘汰的key，并将其从策略中移除。没有可以淘汰的key时ok为false。
+	Victim() (key K, ok bool)
+	// Keys 返回策略中（仍在缓存中的）所有key，越晚被淘汰的key越靠前。对于LRU即从最近访问到最久未访问。
+	// 其他策略的淘汰顺序取决于之后的访问，返回的是当前的近似顺序。
+	Keys() []K
+	// Len 返回策略中（仍在缓存中的）key的数量。
+	Len() int
+}
@@ -0,0 +1,145 @@
+package policy
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// 模拟一个最多容纳capacity个key的缓存，按顺序访问keys，返回命中次数。
+func simulate[K comparable](p Policy[K], capacity int, keys []K) int {
+	hits := 0
+	resident := make(map[K]bool)
+	for _, k := range keys {
+		if resident[k] {
+			hits++
+			p.Access(k)
+			continue
+		}
+		resident[k] = true
+		p.Add(k)
+		for p.Len() > capacity {
+			victim, _ := p.Victim()
+			delete(resident, victim)
+		}
+	}
+	return hits
+}
+
+// 依次淘汰所有的key，返回淘汰顺序。
+func drain(p Policy[string]) []string {
+	var keys []string
+	for {
+		k, ok := p.Victim()
+		if !ok {
+			return keys
+		}
+		keys = append(keys, k)
+	}
+}
+
+func TestLRU(t *testing.T) {
+	p := NewLRU[string]()
+	p.Add("k1")
+	p.Add("k2")
+	p.Add("k3")
+	p.Access("k1")
+	p.Remove("k2")
+
+	if keys := p.Keys(); !reflect.DeepEqual(keys, []string{"k1", "k3"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+	if keys := drain(p); !reflect.DeepEqual(keys, []string{"k3", "k1"}) {
+		t.Fatalf("unexpected eviction order %v", keys)
+	}
+}
+
+func TestLFU(t *testing.T) {
+	p := NewLFU[string]()
+	p.Add("k1")
+	p.Add("k2")
+	p.Add("k3")
+	p.Access("k1")
+	p.Access("k1")
+	p.Access("k3")
+
+	// k2访问1次，k3访问2次，k1访问3次。
+	if keys := p.Keys(); !reflect.DeepEqual(keys, []string{"k1", "k3", "k2"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+	if keys := drain(p); !reflect.DeepEqual(keys, []string{"k2", "k3", "k1"}) {
+		t.Fatalf("unexpected eviction order %v", keys)
+	}
+}
+
+func TestSIEVE(t *testing.T) {
+	p := NewSIEVE[string]()
+	p.Add("k1")
+	p.Add("k2")
+	p.Add("k3")
+	p.Access("k1")
+
+	// k1被访问过，淘汰时跳过k1并清除标记，淘汰k2。
+	if k, _ := p.Victim(); k != "k2" {
+		t.Fatalf("expected k2 to be evicted, got %s", k)
+	}
+	if keys := drain(p); !reflect.DeepEqual(keys, []string{"k3", "k1"}) {
+		t.Fatalf("unexpected eviction order %v", keys)
+	}
+}
+
+// 热点数据被反复访问后，一次批量扫描不应该把热点数据全部冲刷掉。
+func TestScanResistance(t *testing.T) {
+	const capacity = 100
+	var hot, trace []string
+	for i := 0; i < capacity/2; i++ {
+		hot = append(hot, "hot"+strconv.Itoa(i))
+	}
+	for round := 0; round < 5; round++ {
+		trace = append(trace, hot...)
+	}
+	for i := 0; i < 10*capacity; i++ {
+		trace = append(trace, "scan"+strconv.Itoa(i))
+	}
+	trace = append(trace, hot...)
+
+	lruHits := simulate(NewLRU[string](), capacity, trace)
+	for name, p := range map[string]Policy[string]{
+		"LFU":     NewLFU[string](),
+		"ARC":     NewARC[string](),
+		"SIEVE":   NewSIEVE[string](),
+		"TinyLFU": NewTinyLFU[string](),
+	} {
+		// 扫描结束后的最后一轮访问热点数据，应当大部分命中。
+		if hits := simulate(p, capacity, trace); hits < lruHits+len(hot)/2 {
+			t.Errorf("%s: %d hits, LRU: %d hits", name, hits, lruHits)
+		}
+	}
+}
+
+func TestIntKeys(t *testing.T) {
+	p := NewLRU[int]()
+	p.Add(1)
+	p.Add(2)
+	p.Access(1)
+	if k, _ := p.Victim(); k != 2 {
+		t.Fatalf("expected 2 to be evicted, got %d", k)
+	}
+
+	// 整数key的频率同样由sketch估算，热点数据不会被扫描冲刷掉。
+	var trace []int
+	for round := 0; round < 6; round++ {
+		for k := 0; k < 50; k++ {
+			trace = append(trace, k)
+		}
+		if round == 4 {
+			for k := 1000; k < 2000; k++ {
+				trace = append(trace, k)
+			}
+		}
+	}
+	lruHits := simulate(NewLRU[int](), 100, trace)
+	if hits := simulate(NewTinyLFU[int](), 100, trace); hits < lruHits+25 {
+		t.Fatalf("TinyLFU: %d hits, LRU: %d hits", hits, lruHits)
+	}
+}
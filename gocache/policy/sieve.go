@@ -0,0 +1,95 @@
+package policy
+
+import "container/list"
+
+// SIEVE 淘汰策略。所有key按加入顺序排成FIFO队列，命中时只设置visited标记，不移动节点。
+// 淘汰时指针hand从队头向队尾移动，清除经过节点的visited标记，淘汰遇到的第一个未被访问的节点。
+// 命中时不需要修改链表，并且新加入的一次性key会很快被淘汰。
+type SIEVE[K comparable] struct {
+	ll    *list.List // front为最新加入的key，back为最早加入的key。
+	items map[K]*list.Element
+	hand  *list.Element
+}
+
+type sieveNode[K comparable] struct {
+	key     K
+	visited bool
+}
+
+// NewSIEVE 实例化SIEVE策略。
+func NewSIEVE[K comparable]() Policy[K] {
+	return &SIEVE[K]{
+		ll:    list.New(),
+		items: make(map[K]*list.Element),
+	}
+}
+
+func (p *SIEVE[K]) Add(key K) {
+	if _, ok := p.items[key]; ok {
+		p.Access(key)
+		return
+	}
+	p.items[key] = p.ll.PushFront(&sieveNode[K]{key: key})
+}
+
+func (p *SIEVE[K]) Access(key K) {
+	if ele, ok := p.items[key]; ok {
+		ele.Value.(*sieveNode[K]).visited = true
+	}
+}
+
+func (p *SIEVE[K]) Remove(key K) {
+	if ele, ok := p.items[key]; ok {
+		p.remove(ele)
+	}
+}
+
+func (p *SIEVE[K]) Victim() (K, bool) {
+	ele := p.hand
+	if ele == nil {
+		ele = p.ll.Back()
+	}
+	if ele == nil {
+		var zero K
+		return zero, false
+	}
+	for ele.Value.(*sieveNode[K]).visited {
+		ele.Value.(*sieveNode[K]).visited = false
+		if ele = ele.Prev(); ele == nil {
+			// 到达队尾后，从队头重新开始。
+			ele = p.ll.Back()
+		}
+	}
+	key := ele.Value.(*sieveNode[K]).key
+	// 下一次淘汰从被淘汰节点的前一个节点开始。
+	p.hand = ele.Prev()
+	p.ll.Remove(ele)
+	delete(p.items, key)
+	return key, true
+}
+
+func (p *SIEVE[K]) Len() int {
+	return p.ll.Len()
+}
+
+// 被访问过的key（下一轮淘汰时会被跳过）排在前面，其余的key从新到旧排列。
+func (p *SIEVE[K]) Keys() []K {
+	keys := make([]K, 0, p.ll.Len())
+	var unvisited []K
+	for ele := p.ll.Front(); ele != nil; ele = ele.Next() {
+		if node := ele.Value.(*sieveNode[K]); node.visited {
+			keys = append(keys, node.key)
+		} else {
+			unvisited = append(unvisited, node.key)
+		}
+	}
+	return append(keys, unvisited...)
+}
+
+func (p *SIEVE[K]) remove(ele *list.Element) {
+	if p.hand == ele {
+		p.hand = ele.Prev()
+	}
+	p.ll.Remove(ele)
+	delete(p.items, ele.Value.(*sieveNode[K]).key)
+}
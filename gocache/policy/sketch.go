@@ -0,0 +1,113 @@
+package policy
+
+import "fmt"
+
+// cmSketch Count-Min Sketch，用很少的内存估算key的访问频率。
+// 每行使用不同的哈希函数映射到一个计数器，估算值取各行计数器的最小值。计数器最大为15，
+// 累计增加次数达到10倍宽度后所有计数器减半，使频率随时间衰减，让过去的热点数据可以被淘汰。
+type cmSketch struct {
+	rows      [sketchDepth][]uint8
+	mask      uint64
+	additions int
+}
+
+const (
+	sketchDepth    = 4
+	sketchMaxCount = 15
+	minSketchWidth = 64
+)
+
+// 实例化cmSketch，width会向上取整为2的幂。
+func newCMSketch(width int) *cmSketch {
+	w := minSketchWidth
+	for w < width {
+		w <<= 1
+	}
+	s := &cmSketch{mask: uint64(w - 1)}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, w)
+	}
+	return s
+}
+
+// 计算key的64位FNV-1a哈希值。
+func hash64(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// 计算任意类型的key的64位哈希值。字符串和整数直接计算，其他类型先用fmt转换为字符串。
+func hashKey[K comparable](key K) uint64 {
+	switch k := any(key).(type) {
+	case string:
+		return hash64(k)
+	case int:
+		return mix64(uint64(k))
+	case int64:
+		return mix64(uint64(k))
+	case uint64:
+		return mix64(k)
+	case int32:
+		return mix64(uint64(k))
+	case uint32:
+		return mix64(uint64(k))
+	}
+	return hash64(fmt.Sprint(key))
+}
+
+// splitmix64的混合函数，将相邻的整数打散到整个64位空间。
+func mix64(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
+
+// 使用双重哈希为第i行生成下标。
+func (s *cmSketch) index(h uint64, i int) uint64 {
+	return (h + uint64(i)*(h>>32|1)) & s.mask
+}
+
+// 增加哈希值为h的key的计数。
+func (s *cmSketch) increment(h uint64) {
+	for i := range s.rows {
+		if idx := s.index(h, i); s.rows[i][idx] < sketchMaxCount {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= 10*len(s.rows[0]) {
+		s.reset()
+	}
+}
+
+// 估算哈希值为h的key的访问频率。
+func (s *cmSketch) estimate(h uint64) uint8 {
+	est := uint8(sketchMaxCount)
+	for i := range s.rows {
+		if c := s.rows[i][s.index(h, i)]; c < est {
+			est = c
+		}
+	}
+	return est
+}
+
+// 所有计数器减半。
+func (s *cmSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions = 0
+}
+
+func (s *cmSketch) width() int {
+	return len(s.rows[0])
+}
@@ -0,0 +1,110 @@
+package policy
+
+// TinyLFU W-TinyLFU淘汰策略。
+// 新加入的key先进入很小的窗口LRU（约占1%），窗口满时窗口的队头进入主缓存的probation段。
+// 需要淘汰时，probation段最新进入的key（候选者）与队头（淘汰者）比较访问频率，频率较低的一方被淘汰（准入策略），
+// 频率由Count-Min Sketch估算。主缓存是分段LRU：probation段中的key再次被访问时提升到protected段（约占主缓存的80%）。
+// 批量扫描产生的一次性key频率很低，无法挤掉主缓存中的热点数据。
+type TinyLFU[K comparable] struct {
+	sketch                       *cmSketch
+	hash                         func(K) uint64
+	window, probation, protected *LRU[K]
+}
+
+// NewTinyLFU 实例化W-TinyLFU策略。字符串和整数类型的key直接计算哈希值，其他类型的key先用fmt转换为字符串，
+// 这种情况下可以使用NewTinyLFUWithHash提供更快的哈希函数。
+func NewTinyLFU[K comparable]() Policy[K] {
+	return NewTinyLFUWithHash[K](hashKey[K])
+}
+
+// NewTinyLFUWithHash 实例化W-TinyLFU策略，使用hash计算key的64位哈希值，用于估算访问频率。
+func NewTinyLFUWithHash[K comparable](hash func(K) uint64) Policy[K] {
+	return &TinyLFU[K]{
+		sketch:    newCMSketch(minSketchWidth),
+		hash:      hash,
+		window:    newLRU[K](),
+		probation: newLRU[K](),
+		protected: newLRU[K](),
+	}
+}
+
+func (p *TinyLFU[K]) Add(key K) {
+	if p.window.contains(key) || p.probation.contains(key) || p.protected.contains(key) {
+		p.Access(key)
+		return
+	}
+	if n := p.Len() + 1; n > p.sketch.width() {
+		// 缓存中的key数量超过了sketch的宽度，扩容后重新统计频率。
+		p.sketch = newCMSketch(2 * n)
+	}
+	p.sketch.increment(p.hash(key))
+	p.window.Add(key)
+	for p.window.Len() > max(p.Len()/100, 1) {
+		// 窗口超过目标大小，队头进入probation段。
+		k, _ := p.window.Victim()
+		p.probation.Add(k)
+	}
+}
+
+func (p *TinyLFU[K]) Access(key K) {
+	p.sketch.increment(p.hash(key))
+	switch {
+	case p.window.contains(key):
+		p.window.Access(key)
+	case p.probation.contains(key):
+		// 在主缓存中再次被访问，提升到protected段。protected段超过目标大小时，将其队头降级到probation段。
+		p.probation.Remove(key)
+		p.protected.Add(key)
+		if p.protected.Len() > p.mainLen()*4/5 {
+			demoted, _ := p.protected.Victim()
+			p.probation.Add(demoted)
+		}
+	default:
+		p.protected.Access(key)
+	}
+}
+
+func (p *TinyLFU[K]) Remove(key K) {
+	p.window.Remove(key)
+	p.probation.Remove(key)
+	p.protected.Remove(key)
+}
+
+func (p *TinyLFU[K]) Victim() (K, bool) {
+	if p.mainLen() == 0 {
+		return p.window.Victim()
+	}
+	segment := p.mainVictimSegment()
+	victim, _ := segment.back()
+	if candidate, ok := p.probation.front(); ok && candidate != victim {
+		// 准入：候选者的频率不高于淘汰者时，淘汰候选者。
+		if p.sketch.estimate(p.hash(candidate)) <= p.sketch.estimate(p.hash(victim)) {
+			p.probation.Remove(candidate)
+			return candidate, true
+		}
+	}
+	segment.Remove(victim)
+	return victim, true
+}
+
+func (p *TinyLFU[K]) Len() int {
+	return p.window.Len() + p.mainLen()
+}
+
+// protected段的key最晚被淘汰，其次是窗口中刚加入的key，最后是probation段。
+func (p *TinyLFU[K]) Keys() []K {
+	keys := append(p.protected.Keys(), p.window.Keys()...)
+	return append(keys, p.probation.Keys()...)
+}
+
+func (p *TinyLFU[K]) mainLen() int {
+	return p.probation.Len() + p.protected.Len()
+}
+
+// 主缓存优先从probation段淘汰。
+func (p *TinyLFU[K]) mainVictimSegment() *LRU[K] {
+	if p.probation.Len() > 0 {
+		return p.probation
+	}
+	return p.protected
+}
@@ -0,0 +1,161 @@
+package gocache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PoolPolicy 共享内存池超过上限时，选择从哪个Group淘汰缓存项的策略。
+type PoolPolicy int
+
+const (
+	// PoolWeighted 按照权重计算每个Group应得的份额（不低于最少保留的内存），从超出份额最多的Group中，
+	// 按照该Group自己的淘汰策略淘汰缓存项。
+	PoolWeighted PoolPolicy = iota
+	// PoolGlobalLRU 在所有Group中近似地淘汰最久没有被访问的缓存项：借鉴Redis的近似LRU，
+	// 从每个Group中随机抽查poolSamples条记录，淘汰其中最久没有被访问的一条。已使用的内存不超过最少保留内存的Group不会被淘汰。
+	PoolGlobalLRU
+)
+
+// 使用PoolGlobalLRU时，每次淘汰在每个Group中抽查的记录数。
+const poolSamples = 5
+
+// MemoryPool 多个Group共享的内存池，所有加入内存池的Group的mainCache已经使用的内存之和不超过上限。
+// 每个Group的cacheBytes仍然是该Group单独的上限，设置为0代表只受内存池的限制。hotCache和负缓存不计入内存池。
+type MemoryPool struct {
+	policy PoolPolicy
+	limit  atomic.Int64
+	start  time.Time // 记录访问时间的单调时钟的起点，所有Group共用，访问时间才能互相比较。
+
+	mu      sync.Mutex                    // 保证同一时刻只有一个协程在淘汰或者加入Group。
+	members atomic.Pointer[[]*poolMember] // 写时复制，读取时不需要加锁。
+}
+
+// 加入内存池的Group。
+type poolMember struct {
+	group   *Group
+	weight  int64
+	reserve int64
+}
+
+// 加入内存池时的参数。
+type poolConfig struct {
+	pool    *MemoryPool
+	weight  int64
+	reserve int64
+}
+
+// NewMemoryPool 实例化内存池，limit是所有Group共享的内存上限。
+func NewMemoryPool(limit int64, policy PoolPolicy) *MemoryPool {
+	p := &MemoryPool{policy: policy, start: time.Now()}
+	p.limit.Store(limit)
+	p.members.Store(&[]*poolMember{})
+	return p
+}
+
+// SetLimit 在运行时修改内存上限，缩小上限时立即淘汰超出的缓存项。
+func (p *MemoryPool) SetLimit(limit int64) {
+	p.limit.Store(limit)
+	p.enforce()
+}
+
+// Limit 返回内存上限。
+func (p *MemoryPool) Limit() int64 {
+	return p.limit.Load()
+}
+
+// Used 返回所有Group已经使用的内存之和。
+func (p *MemoryPool) Used() int64 {
+	var used int64
+	for _, m := range *p.members.Load() {
+		used += m.group.mainCache.bytes()
+	}
+	return used
+}
+
+// 将Group加入内存池。
+func (p *MemoryPool) register(g *Group, weight, reserve int64) {
+	if p.policy == PoolGlobalLRU {
+		// 使用单调时钟记录访问时间，不受系统时间调整的影响。
+		g.mainCache.setClock(func() int64 {
+			return int64(time.Since(p.start))
+		})
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	members := append([]*poolMember(nil), *p.members.Load()...)
+	members = append(members, &poolMember{group: g, weight: weight, reserve: reserve})
+	p.members.Store(&members)
+}
+
+// 超过内存上限时循环淘汰缓存项，直到不超过上限。在Group写入mainCache之后调用。
+func (p *MemoryPool) enforce() {
+	if p.Used() <= p.limit.Load() {
+		// 没有超过上限时不需要加锁。
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.Used() > p.limit.Load() {
+		if !p.evictOne() {
+			return
+		}
+	}
+}
+
+// 按照策略淘汰一条缓存项，没有可以淘汰的缓存项时返回false。
+func (p *MemoryPool) evictOne() bool {
+	if p.policy == PoolGlobalLRU {
+		return p.evictOldest()
+	}
+	var (
+		victim      *poolMember
+		maxOver     int64
+		totalWeight int64
+	)
+	members := *p.members.Load()
+	for _, m := range members {
+		totalWeight += m.weight
+	}
+	limit := p.limit.Load()
+	for _, m := range members {
+		used := m.group.mainCache.bytes()
+		if used <= 0 {
+			continue
+		}
+		// 超出份额（以及最少保留内存）的部分。所有Group都没有超出时（最少保留内存之和超过了上限），淘汰相对超出最多的Group。
+		floor := limit * m.weight / totalWeight
+		if m.reserve > floor {
+			floor = m.reserve
+		}
+		if over := used - floor; victim == nil || over > maxOver {
+			victim, maxOver = m, over
+		}
+	}
+	return victim != nil && victim.group.mainCache.evictOne()
+}
+
+// 在所有Group中抽查，淘汰最久没有被访问的缓存项。
+func (p *MemoryPool) evictOldest() bool {
+	var (
+		victim    *poolMember
+		victimKey string
+		oldest    int64
+	)
+	for _, reserved := range []bool{true, false} {
+		for _, m := range *p.members.Load() {
+			if reserved && m.group.mainCache.bytes() <= m.reserve {
+				continue
+			}
+			if key, atime, ok := m.group.mainCache.sampleOldest(poolSamples); ok && (victim == nil || atime < oldest) {
+				victim, victimKey, oldest = m, key, atime
+			}
+		}
+		if victim != nil {
+			break
+		}
+		// 所有Group都没有超出最少保留的内存（最少保留内存之和超过了上限），忽略最少保留内存。
+	}
+	return victim != nil && victim.group.mainCache.evict(victimKey)
+}
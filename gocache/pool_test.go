@@ -0,0 +1,114 @@
+package gocache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// 每个key对应的值都是"v"+key，key的长度相同时每条缓存项占用的内存相同。
+func newPoolGroup(name string, pool *MemoryPool, weight int, reserve int64) *Group {
+	return NewGroup(name, 0, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte("v" + key), nil
+		}), WithShards(1), WithMemoryPool(pool, weight, reserve))
+}
+
+func fillGroup(g *Group, prefix string, n int) {
+	for i := 0; i < n; i++ {
+		g.Get(context.Background(), fmt.Sprintf("%s%d", prefix, i))
+	}
+}
+
+func TestMemoryPoolWeighted(t *testing.T) {
+	size := entrySize("a0", ByteView{b: []byte("va0")})
+	pool := NewMemoryPool(4*size, PoolWeighted)
+	a := newPoolGroup("pool-weighted-a", pool, 1, 0)
+	b := newPoolGroup("pool-weighted-b", pool, 1, 0)
+
+	fillGroup(a, "a", 4)
+	fillGroup(b, "b", 2)
+	if pool.Used() > pool.Limit() {
+		t.Fatalf("pool used %d exceeds limit %d", pool.Used(), pool.Limit())
+	}
+	if a.CacheStats(MainCache).Items != 2 || b.CacheStats(MainCache).Items != 2 {
+		t.Fatalf("expected 2 items in each group, got %d and %d",
+			a.CacheStats(MainCache).Items, b.CacheStats(MainCache).Items)
+	}
+	// 组内仍然按照LRU淘汰。
+	if a.Contains("a0") || a.Contains("a1") || !a.Contains("a3") {
+		t.Fatalf("unexpected keys %v", a.Keys(MainCache))
+	}
+
+	pool.SetLimit(2 * size)
+	if pool.Used() > 2*size {
+		t.Fatalf("pool used %d exceeds new limit %d", pool.Used(), 2*size)
+	}
+	if a.CacheStats(MainCache).Items != 1 || b.CacheStats(MainCache).Items != 1 {
+		t.Fatalf("expected 1 item in each group, got %d and %d",
+			a.CacheStats(MainCache).Items, b.CacheStats(MainCache).Items)
+	}
+}
+
+func TestMemoryPoolReserve(t *testing.T) {
+	size := entrySize("a0", ByteView{b: []byte("va0")})
+	pool := NewMemoryPool(6*size, PoolWeighted)
+	a := newPoolGroup("pool-reserve-a", pool, 1, 0)
+	b := newPoolGroup("pool-reserve-b", pool, 1, 4*size)
+
+	fillGroup(b, "b", 4)
+	fillGroup(a, "a", 4)
+	if b.CacheStats(MainCache).Items != 4 {
+		t.Fatalf("reserved group should keep 4 items, got %v", b.Keys(MainCache))
+	}
+	if a.CacheStats(MainCache).Items != 2 {
+		t.Fatalf("expected 2 items, got %v", a.Keys(MainCache))
+	}
+}
+
+func TestMemoryPoolGlobalLRU(t *testing.T) {
+	size := entrySize("a0", ByteView{b: []byte("va0")})
+	pool := NewMemoryPool(4*size, PoolGlobalLRU)
+	a := newPoolGroup("pool-lru-a", pool, 1, 0)
+	b := newPoolGroup("pool-lru-b", pool, 1, 0)
+
+	ctx := context.Background()
+	fillGroup(b, "b", 2)
+	fillGroup(a, "a", 2)
+	// b1成为所有Group中最近访问的缓存项，b0最久没有被访问。
+	b.Get(ctx, "b1")
+	fillGroup(a, "c", 1)
+	if b.Contains("b0") || !b.Contains("b1") || !a.Contains("a0") || !a.Contains("a1") || !a.Contains("c0") {
+		t.Fatalf("unexpected keys %v and %v", a.Keys(MainCache), b.Keys(MainCache))
+	}
+
+	// a0和a1比b1更久没有被访问。
+	fillGroup(b, "c", 2)
+	if a.Contains("a0") || a.Contains("a1") || !b.Contains("b1") {
+		t.Fatalf("unexpected keys %v and %v", a.Keys(MainCache), b.Keys(MainCache))
+	}
+	if pool.Used() > pool.Limit() {
+		t.Fatalf("pool used %d exceeds limit %d", pool.Used(), pool.Limit())
+	}
+}
+
+func TestMemoryPoolDerivedCaches(t *testing.T) {
+	pool := NewMemoryPool(1<<20, PoolWeighted)
+	g := NewGroup("pool-derived", 0, GetterFunc(
+		func(key string) ([]byte, error) {
+			return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+		}), WithMemoryPool(pool, 1, 0), WithNegativeTTL(time.Minute))
+
+	// 只受内存池限制时，hotCache和negCache仍然有容量上限。
+	if g.hotCacheBytes != (1<<20)/defaultHotCacheRatio {
+		t.Fatalf("expected hot cache limit derived from pool, got %d", g.hotCacheBytes)
+	}
+	limit := int64((1 << 20) / defaultNegCacheRatio)
+	for i := 0; i < 2000; i++ {
+		g.Get(context.Background(), fmt.Sprintf("missing%d", i))
+	}
+	if used := g.negCache.bytes(); used == 0 || used > limit {
+		t.Fatalf("expected negative cache bounded by %d, got %d", limit, used)
+	}
+}
@@ -1,12 +1,50 @@
 package singleflight
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
 
-// call 代表正在进行中，或已经结束的请求。使用sync.WaitGroup锁避免重入。
+// fn调用了runtime.Goexit时，DoChan返回的错误。
+var errGoexit = errors.New("runtime.Goexit was called")
+
+// panicError fn发生panic时记录的值和调用栈，重新panic给所有等待的调用方。
+type panicError struct {
+	value interface{}
+	stack []byte
+}
+
+func (p *panicError) Error() string {
+	return fmt.Sprintf("%v\n\n%s", p.value, p.stack)
+}
+
+// Unwrap panic的值是error时返回该error。
+func (p *panicError) Unwrap() error {
+	err, _ := p.value.(error)
+	return err
+}
+
+// Result DoChan返回的结果。Shared代表结果是否被多个调用方共享。
+type Result struct {
+	Val    interface{}
+	Err    error
+	Shared bool
+}
+
+// call 代表正在进行中，或已经结束的请求。请求结束时关闭done，唤醒所有等待的协程。
 type call struct {
-	wg  sync.WaitGroup
-	val interface{}
-	err error
+	done   chan struct{}
+	val    interface{}
+	err    error
+	shared bool // 结果是否被多个调用方共享，在关闭done之前写入。
+
+	// 以下字段在持有Group.mu时访问。
+	dups  int             // 等待同一个请求的其他调用次数。
+	chans []chan<- Result // DoChan的调用方，请求结束时发送结果。
 }
 
 // Group singleFlight的主数据结构，管理不同的key的请求(call)。
@@ -16,9 +54,12 @@ type Group struct {
 }
 
 // Do 作用是针对相同的key，无论Do被调用多少次，函数fn都只会被调用一次。等待fn调用结束，返回返回值或者错误。
-// 并发协程之间不需要消息传递，非常适合sync.WaitGroup。
-// wg.Add(1)锁加1。 	wg.wait()阻塞，直到锁被释放。	wg.Done()锁减1。
-func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+// fn在新的协程中执行，发起请求的协程和等待的协程一样，在各自的ctx结束时放弃等待，返回ctx.Err()，
+// 但不会影响正在进行的请求，其他调用方仍然收到fn的结果。fn需要自己控制超时，不应该使用某一个调用方的ctx。
+// 使用channel而不是sync.WaitGroup，是为了能够在等待的同时select ctx.Done()。
+// fn发生panic或者调用runtime.Goexit时，所有通过Do等待该请求的协程同样会panic（值包含fn的调用栈）或者退出。
+// shared代表结果是否被多个调用方共享，因为ctx结束而放弃等待时为false。
+func (g *Group) Do(ctx context.Context, key string, fn func() (interface{}, error)) (v interface{}, err error, shared bool) {
 	g.mu.Lock() // 加锁，防止并发读写Group.m。
 	if g.m == nil {
 		// 延迟初始化，提高内存使用效率。
@@ -27,21 +68,123 @@ func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, err
 
 	if c, ok := g.m[key]; ok {
 		// 有其他请求正在获取该key，等待。
-		g.mu.Unlock()       // 释放锁，让其他请求进入Do方法。
-		c.wg.Wait()         // 如果有请求正在进行中，则等待。
-		return c.val, c.err // 请求结束，返回结果。
+		c.dups++
+		g.mu.Unlock() // 释放锁，让其他请求进入Do方法。
+		return c.wait(ctx)
 	} // 没有其他请求在进行。
-	c := new(call) // 初始化call。
-	c.wg.Add(1)    // 准备发起请求，发起请求前加锁，锁+1。
-	g.m[key] = c   // 添加到g.m中，表明key已经有对应的请求在处理。对应上面的if判断。
-	g.mu.Unlock()  // 释放锁，让其他请求进入Do方法。
+	c := &call{done: make(chan struct{})} // 初始化call。
+	g.m[key] = c                          // 添加到g.m中，表明key已经有对应的请求在处理。对应上面的if判断。
+	g.mu.Unlock()                         // 释放锁，让其他请求进入Do方法。
 
-	c.val, c.err = fn() // 调用fn，发起请求。
-	c.wg.Done()         // 请求结束。锁-1。
+	go g.doCall(c, key, fn) // 在新的协程中调用fn，发起请求。
+	return c.wait(ctx)      // 等待结果。
+}
+
+// DoChan 与Do相同，但是不阻塞，返回一个接收结果的channel，调用方可以同时select超时等条件。
+// fn在新的协程中执行。fn调用runtime.Goexit时，Err是一个非nil的错误；
+// fn发生panic时，channel无法传递panic，为了不让调用方永远阻塞，进程会以该panic崩溃。
+func (g *Group) DoChan(key string, fn func() (interface{}, error)) <-chan Result {
+	ch, _ := g.DoChanOwner(key, fn)
+	return ch
+}
 
+// DoChanOwner 与DoChan相同，owner为true代表本次调用发起了新的请求，fn会被调用；
+// 为false代表加入了其他调用方正在进行的请求，fn不会被调用。
+func (g *Group) DoChanOwner(key string, fn func() (interface{}, error)) (ch <-chan Result, owner bool) {
+	res := make(chan Result, 1)
 	g.mu.Lock()
-	delete(g.m, key) // 更新g.m。
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		c.chans = append(c.chans, res)
+		g.mu.Unlock()
+		return res, false
+	}
+	c := &call{done: make(chan struct{}), chans: []chan<- Result{res}}
+	g.m[key] = c
 	g.mu.Unlock()
 
-	return c.val, c.err // 返回结果
+	go g.doCall(c, key, fn)
+	return res, true
+}
+
+// Forget 忘记key对应的正在进行中的请求，之后对该key的Do和DoChan会重新调用fn，而不是等待这个请求。
+// 已经在等待的调用方仍然收到这个请求的结果。
+func (g *Group) Forget(key string) {
+	g.mu.Lock()
+	delete(g.m, key)
+	g.mu.Unlock()
+}
+
+// 调用fn并记录结果，捕获fn中的panic和runtime.Goexit，然后唤醒所有等待的调用方。
+func (g *Group) doCall(c *call, key string, fn func() (interface{}, error)) {
+	normalReturn := false
+	recovered := false
+
+	// 使用两层defer区分panic和runtime.Goexit：recover()只能捕获panic，runtime.Goexit同样会执行defer，但无法被recover。
+	defer func() {
+		if !normalReturn && !recovered {
+			c.err = errGoexit
+		}
+
+		g.mu.Lock()
+		if g.m[key] == c {
+			// 调用过Forget时，g.m[key]可能已经是新的请求。
+			delete(g.m, key)
+		}
+		c.shared = c.dups > 0
+		chans := c.chans
+		g.mu.Unlock()
+		close(c.done) // 请求结束，唤醒等待的协程。
+
+		if e, ok := c.err.(*panicError); ok && len(chans) > 0 {
+			// DoChan的调用方无法接收panic。在新的协程中panic，使进程崩溃而不是让调用方永远阻塞，
+			// 并且保留当前协程，使崩溃信息中包含它的调用栈。
+			go panic(e)
+			select {}
+		}
+		for _, ch := range chans {
+			ch <- Result{Val: c.val, Err: c.err, Shared: c.shared}
+		}
+	}()
+
+	func() {
+		defer func() {
+			if !normalReturn {
+				if r := recover(); r != nil {
+					c.err = &panicError{value: r, stack: debug.Stack()}
+				}
+			}
+		}()
+		c.val, c.err = fn()
+		normalReturn = true
+	}()
+
+	if !normalReturn {
+		// 执行到这里说明fn发生了panic并且已经被捕获；runtime.Goexit不会执行到这里。
+		recovered = true
+	}
+}
+
+// 等待请求结束并返回结果，ctx结束时放弃等待。
+func (c *call) wait(ctx context.Context) (interface{}, error, bool) {
+	select {
+	case <-c.done: // 请求结束，返回结果。
+		return c.result()
+	case <-ctx.Done(): // 等待超时或被取消。
+		return nil, ctx.Err(), false
+	}
+}
+
+// 返回请求的结果。fn发生panic时重新panic，调用runtime.Goexit时同样退出当前协程。
+func (c *call) result() (interface{}, error, bool) {
+	if e, ok := c.err.(*panicError); ok {
+		panic(e)
+	}
+	if c.err == errGoexit {
+		runtime.Goexit()
+	}
+	return c.val, c.err, c.shared
 }
@@ -0,0 +1,288 @@
+package singleflight
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	var g Group
+	v, err, shared := g.Do(context.Background(), "key", func() (interface{}, error) {
+		return "bar", nil
+	})
+	if v != "bar" || err != nil || shared {
+		t.Fatalf("Do = %v, %v, %v", v, err, shared)
+	}
+}
+
+func TestDoDedup(t *testing.T) {
+	var (
+		g     Group
+		calls int32
+		wg    sync.WaitGroup
+	)
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "bar", nil
+	}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err, shared := g.Do(context.Background(), "key", fn); v != "bar" || err != nil || !shared {
+				t.Errorf("Do = %v, %v, %v", v, err, shared)
+			}
+		}()
+	}
+	// 等待所有协程进入Do。
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("fn called %d times, want 1", n)
+	}
+}
+
+func TestDoContextCanceled(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	go g.Do(context.Background(), "key", func() (interface{}, error) {
+		<-release
+		return "bar", nil
+	})
+	time.Sleep(10 * time.Millisecond)
+
+	// 等待的协程在ctx结束时放弃等待。
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err, _ := g.Do(ctx, "key", func() (interface{}, error) {
+		t.Error("fn should not be called while another call is in flight")
+		return nil, nil
+	}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	close(release)
+}
+
+func TestDoLeaderCanceled(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		return "bar", nil
+	}
+	// 发起请求的协程放弃等待时，fn继续执行，等待的协程仍然收到结果。
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, err, _ := g.Do(ctx, "key", fn)
+		leader <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	waiter := make(chan interface{}, 1)
+	go func() {
+		v, _, _ := g.Do(context.Background(), "key", fn)
+		waiter <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancel()
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected Canceled, got %v", err)
+	}
+	close(release)
+	if v := <-waiter; v != "bar" {
+		t.Fatalf("expected bar, got %v", v)
+	}
+}
+
+func TestDoChan(t *testing.T) {
+	var (
+		g     Group
+		calls int32
+	)
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "bar", nil
+	}
+	ch1, owner1 := g.DoChanOwner("key", fn)
+	ch2, owner2 := g.DoChanOwner("key", fn)
+	if !owner1 || owner2 {
+		t.Fatalf("only the first caller should own the call, got %v, %v", owner1, owner2)
+	}
+
+	// 请求没有结束时，调用方可以select超时。
+	select {
+	case <-ch1:
+		t.Fatal("DoChan should not return before fn returns")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	for _, ch := range []<-chan Result{ch1, ch2} {
+		if r := <-ch; r.Val != "bar" || r.Err != nil || !r.Shared {
+			t.Fatalf("unexpected result %+v", r)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("fn called %d times, want 1", n)
+	}
+
+	// 只有一个调用方时结果没有被共享。
+	r := <-g.DoChan("key", func() (interface{}, error) { return "baz", nil })
+	if r.Val != "baz" || r.Shared {
+		t.Fatalf("unexpected result %+v", r)
+	}
+}
+
+func TestForget(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	first := g.DoChan("key", func() (interface{}, error) {
+		<-release
+		return 1, nil
+	})
+	g.Forget("key")
+
+	// Forget之后重新调用fn，不再等待第一个请求。
+	if v, err, _ := g.Do(context.Background(), "key", func() (interface{}, error) {
+		return 2, nil
+	}); v != 2 || err != nil {
+		t.Fatalf("Do = %v, %v", v, err)
+	}
+
+	// 第三个请求在第一个请求结束之前开始，第一个请求结束时不能把它从g.m中删除。
+	third := make(chan struct{})
+	ch := g.DoChan("key", func() (interface{}, error) {
+		<-third
+		return 3, nil
+	})
+	close(release)
+	if r := <-first; r.Val != 1 {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	dup := g.DoChan("key", func() (interface{}, error) {
+		t.Error("fn should not be called while the third call is in flight")
+		return nil, nil
+	})
+	close(third)
+	for _, c := range []<-chan Result{ch, dup} {
+		if r := <-c; r.Val != 3 {
+			t.Fatalf("unexpected result %+v", r)
+		}
+	}
+}
+
+func TestDoPanic(t *testing.T) {
+	var (
+		g  Group
+		wg sync.WaitGroup
+	)
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		panic("boom")
+	}
+	panics := make(chan interface{}, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { panics <- recover() }()
+			g.Do(context.Background(), "key", fn)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(panics)
+
+	// 发起请求的协程和所有等待的协程都收到同一个panic。
+	for r := range panics {
+		e, ok := r.(*panicError)
+		if !ok || e.value != "boom" || !strings.Contains(string(e.stack), "TestDoPanic") {
+			t.Fatalf("unexpected panic %v", r)
+		}
+	}
+	// panic之后key被删除，不会让之后的调用永远阻塞。
+	if v, err, _ := g.Do(context.Background(), "key", func() (interface{}, error) {
+		return "bar", nil
+	}); v != "bar" || err != nil {
+		t.Fatalf("Do = %v, %v", v, err)
+	}
+}
+
+func TestDoGoexit(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		runtime.Goexit()
+		return nil, nil
+	}
+
+	returned := make(chan bool, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			defer func() {
+				// runtime.Goexit仍然会执行defer，但是Do不会返回。
+				if r := recover(); r != nil {
+					t.Errorf("unexpected panic %v", r)
+				}
+			}()
+			g.Do(context.Background(), "key", fn)
+			returned <- true
+		}()
+	}
+	ch := g.DoChan("key", fn)
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if r := <-ch; !errors.Is(r.Err, errGoexit) || !r.Shared {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	select {
+	case <-returned:
+		t.Fatal("Do should not return after runtime.Goexit")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if v, err, _ := g.Do(context.Background(), "key", func() (interface{}, error) {
+		return "bar", nil
+	}); v != "bar" || err != nil {
+		t.Fatalf("Do = %v, %v", v, err)
+	}
+}
+
+// DoChan的调用方无法接收panic，进程应该崩溃而不是永远阻塞。在子进程中运行，检查退出状态和输出。
+func TestDoChanPanic(t *testing.T) {
+	if os.Getenv("TEST_DOCHAN_PANIC") == "1" {
+		var g Group
+		<-g.DoChan("key", func() (interface{}, error) {
+			panic("boom")
+		})
+		t.Fatal("DoChan should not return after fn panics")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestDoChanPanic$")
+	cmd.Env = append(os.Environ(), "TEST_DOCHAN_PANIC=1")
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Success() {
+		t.Fatalf("expected the process to crash, got %v\n%s", err, out)
+	}
+	if !bytes.Contains(out, []byte("panic: boom")) {
+		t.Fatalf("expected panic output, got:\n%s", out)
+	}
+}
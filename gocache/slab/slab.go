@@ -0,0 +1,422 @@
+package slab
+
+import (
+	"GoCache/gocache/lru"
+	"encoding/binary"
+	"hash/maphash"
+	"time"
+)
+
+// DefaultSlabSize 默认每块slab的大小。
+const DefaultSlabSize = 1 << 20
+
+// 记录头的布局：总长度(4) + key的长度(4) + key的哈希值(8) + 过期时间(8) + 访问时间(8)，之后是key和value的内容。
+const (
+	headerSize = 32
+	// key长度为该值的记录头代表slab末尾的填充，不是真正的记录。
+	paddingMark = ^uint32(0)
+)
+
+// Cache 将所有记录紧凑地写入少量大块的[]byte（slab）组成的环形缓冲区，字典中只保存key的哈希值到记录偏移量的映射，
+// 不包含任何指针。与lru.Cache相比，GC不需要逐条扫描数百万个entry、链表节点和切片，堆中的指针数量与记录数无关。
+// 代价是Get需要将值复制出来；按照写入顺序（FIFO）淘汰；被删除或者被更新的记录占用的空间要等到环形缓冲区绕回来时才能复用。
+// 在并发访问下线程不安全。
+type Cache struct {
+	// 允许使用的最大内存，即环形缓冲区中[tail, head)的最大长度。
+	maxBytes int64
+	// 每块slab的大小，单条记录（包括记录头）不能超过该大小。不超过maxBytes。
+	slabSize int64
+	// New时指定的slab大小，Resize时按照新容量重新计算slabSize。
+	configSlabSize int64
+	// 按需分配的slab，最多ceil(maxBytes/slabSize)块。
+	slabs [][]byte
+	// 环形缓冲区的物理大小，slabSize的整数倍。
+	ringSize int64
+	// 逻辑偏移量，[tail, head)是已经写入的区域，物理位置是offset % ringSize。逻辑偏移量只增不减，不会被复用。
+	head, tail uint64
+	// key的哈希值 -> 记录的逻辑偏移量。哈希冲突时后写入的key覆盖先写入的key。
+	index map[uint64]uint64
+	seed  maphash.Seed
+	// 允许保存的最大记录数。默认值0代表不限制，修改后在下一次Add时生效。
+	MaxEntries int
+	// 返回当前时间，用于记录每条记录最近一次被访问的时间（见SampleOldest）。为nil时不记录。
+	Clock func() int64
+	// 某条记录被移除（或者被新值替换）时的回调函数，可以为nil。value指向slab中的内存，只在回调期间有效。
+	OnEvicted func(key string, value []byte, reason lru.EvictReason)
+}
+
+// New 实例化Cache，maxBytes必须大于0。slabSize <= 0时使用DefaultSlabSize，并且不超过maxBytes。
+func New(maxBytes, slabSize int64, onEvicted func(string, []byte, lru.EvictReason)) *Cache {
+	if maxBytes <= 0 {
+		panic("slab: maxBytes must be positive")
+	}
+	if slabSize <= 0 {
+		slabSize = DefaultSlabSize
+	}
+	c := &Cache{configSlabSize: slabSize, OnEvicted: onEvicted, seed: maphash.MakeSeed()}
+	c.reset(maxBytes)
+	return c
+}
+
+// 按照新的容量清空所有的slab和索引，不调用回调函数。
+func (c *Cache) reset(maxBytes int64) {
+	slabSize := c.configSlabSize
+	if slabSize > maxBytes {
+		slabSize = maxBytes
+	}
+	c.maxBytes = maxBytes
+	c.slabSize = slabSize
+	c.ringSize = (maxBytes + slabSize - 1) / slabSize * slabSize
+	c.slabs = nil
+	c.head, c.tail = 0, 0
+	c.index = make(map[uint64]uint64)
+}
+
+// 一条记录在slab中的位置和内容，key和value指向slab中的内存。
+type record struct {
+	off    uint64
+	size   int64
+	hash   uint64
+	expire int64
+	atime  int64
+	key    []byte
+	value  []byte
+}
+
+func (r *record) expired(now int64) bool {
+	return r.expire != 0 && now > r.expire
+}
+
+// 返回逻辑偏移量off对应的slab，以及在slab中的位置。
+func (c *Cache) locate(off uint64) ([]byte, int64) {
+	pos := int64(off % uint64(c.ringSize))
+	return c.slabs[pos/c.slabSize], pos % c.slabSize
+}
+
+// 读取逻辑偏移量off处的记录头和内容，off必须指向一条真正的记录。
+func (c *Cache) read(off uint64) record {
+	slab, pos := c.locate(off)
+	h := slab[pos : pos+headerSize]
+	r := record{
+		off:    off,
+		size:   int64(binary.LittleEndian.Uint32(h[0:])),
+		hash:   binary.LittleEndian.Uint64(h[8:]),
+		expire: int64(binary.LittleEndian.Uint64(h[16:])),
+		atime:  int64(binary.LittleEndian.Uint64(h[24:])),
+	}
+	keyLen := int64(binary.LittleEndian.Uint32(h[4:]))
+	r.key = slab[pos+headerSize : pos+headerSize+keyLen]
+	r.value = slab[pos+headerSize+keyLen : pos+r.size]
+	return r
+}
+
+// 查找key对应的记录，哈希值相同但key不同时按未命中处理。
+func (c *Cache) lookup(key string) (record, bool) {
+	off, ok := c.index[maphash.String(c.seed, key)]
+	if !ok {
+		return record{}, false
+	}
+	r := c.read(off)
+	if string(r.key) != key {
+		return record{}, false
+	}
+	return r, true
+}
+
+// Get 查找key对应的值，返回值的副本。过期的记录会被删除，按未命中处理。
+func (c *Cache) Get(key string) (value []byte, ok bool) {
+	r, ok := c.lookup(key)
+	if !ok {
+		return nil, false
+	}
+	if r.expired(time.Now().UnixNano()) {
+		// 惰性删除：记录已经过期，删除后按未命中处理。
+		c.removeRecord(r, lru.EvictExpired)
+		return nil, false
+	}
+	if c.Clock != nil {
+		slab, pos := c.locate(r.off)
+		binary.LittleEndian.PutUint64(slab[pos+24:], uint64(c.Clock()))
+	}
+	return append([]byte(nil), r.value...), true
+}
+
+// Peek 查找key对应的值，返回值的副本，但不更新访问时间。过期的记录按未命中处理（但不删除）。
+func (c *Cache) Peek(key string) (value []byte, ok bool) {
+	r, ok := c.lookup(key)
+	if !ok || r.expired(time.Now().UnixNano()) {
+		return nil, false
+	}
+	return append([]byte(nil), r.value...), true
+}
+
+// Contains 判断key是否在缓存中（且没有过期）。
+func (c *Cache) Contains(key string) bool {
+	r, ok := c.lookup(key)
+	return ok && !r.expired(time.Now().UnixNano())
+}
+
+// Add 新增/更新功能，记录永不过期。
+func (c *Cache) Add(key string, value []byte) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire 新增/更新功能，记录在expire时刻过期。expire为零值代表永不过期。
+// 更新时旧记录只是从索引中删除，占用的空间等到环形缓冲区绕回来时才会复用。
+// 记录（包括32字节的记录头）超过slab大小时无法保存，以EvictCapacity调用回调函数后丢弃，与lru.Cache的行为一致。
+func (c *Cache) AddWithExpire(key string, value []byte, expire time.Time) {
+	var atime int64
+	if c.Clock != nil {
+		atime = c.Clock()
+	}
+	c.add(key, value, expire, atime)
+}
+
+// 写入一条记录，atime是记录的访问时间。
+func (c *Cache) add(key string, value []byte, expire time.Time, atime int64) {
+	if old, ok := c.lookup(key); ok {
+		c.removeRecord(old, lru.EvictReplaced)
+	}
+	size := int64(headerSize + len(key) + len(value))
+	if size > c.slabSize {
+		if c.OnEvicted != nil {
+			c.OnEvicted(key, value, lru.EvictCapacity)
+		}
+		return
+	}
+	if c.MaxEntries != 0 {
+		for len(c.index) >= c.MaxEntries && c.RemoveOldest() {
+		}
+	}
+
+	// 记录不能跨越slab，剩余空间不够时跳到下一块slab的开头。
+	pos := int64(c.head % uint64(c.ringSize))
+	if rest := c.slabSize - pos%c.slabSize; rest < size {
+		c.makeRoom(rest)
+		c.writePadding(rest)
+	}
+	c.makeRoom(size)
+
+	if i := int64(c.head%uint64(c.ringSize)) / c.slabSize; i >= int64(len(c.slabs)) {
+		// 第一次写到这块slab，一次性分配整块内存。
+		c.slabs = append(c.slabs, make([]byte, c.slabSize))
+	}
+	slab, pos := c.locate(c.head)
+	hash := maphash.String(c.seed, key)
+	var exp int64
+	if !expire.IsZero() {
+		exp = expire.UnixNano()
+	}
+	h := slab[pos : pos+headerSize]
+	binary.LittleEndian.PutUint32(h[0:], uint32(size))
+	binary.LittleEndian.PutUint32(h[4:], uint32(len(key)))
+	binary.LittleEndian.PutUint64(h[8:], hash)
+	binary.LittleEndian.PutUint64(h[16:], uint64(exp))
+	binary.LittleEndian.PutUint64(h[24:], uint64(atime))
+	copy(slab[pos+headerSize:], key)
+	copy(slab[pos+headerSize+int64(len(key)):], value)
+
+	if off, ok := c.index[hash]; ok {
+		// 哈希冲突：不同的key有相同的哈希值，先写入的key被覆盖。
+		c.removeRecord(c.read(off), lru.EvictCapacity)
+	}
+	c.index[hash] = c.head
+	c.head += uint64(size)
+}
+
+// 从tail开始淘汰，直到再写入n字节也不超过最大内存。
+func (c *Cache) makeRoom(n int64) {
+	for int64(c.head-c.tail)+n > c.maxBytes && c.tail < c.head {
+		c.advanceTail()
+	}
+}
+
+// 在head处写入n字节的填充，使head对齐到下一块slab的开头。
+func (c *Cache) writePadding(n int64) {
+	if n >= headerSize {
+		slab, pos := c.locate(c.head)
+		binary.LittleEndian.PutUint32(slab[pos:], uint32(n))
+		binary.LittleEndian.PutUint32(slab[pos+4:], paddingMark)
+	}
+	c.head += uint64(n)
+}
+
+// 跳过tail处的一条记录（或者填充），记录仍然在索引中时以EvictCapacity淘汰。返回是否淘汰了一条记录。
+func (c *Cache) advanceTail() bool {
+	slab, pos := c.locate(c.tail)
+	if rest := c.slabSize - pos; rest < headerSize || binary.LittleEndian.Uint32(slab[pos+4:]) == paddingMark {
+		// 剩余空间写不下记录头时没有写入填充，直接跳到下一块slab的开头。
+		c.tail += uint64(rest)
+		return false
+	}
+	r := c.read(c.tail)
+	c.tail += uint64(r.size)
+	if off, ok := c.index[r.hash]; ok && off == r.off {
+		c.removeRecord(r, lru.EvictCapacity)
+		return true
+	}
+	return false
+}
+
+// 从索引中删除记录r并调用回调函数。
+func (c *Cache) removeRecord(r record, reason lru.EvictReason) {
+	delete(c.index, r.hash)
+	if c.OnEvicted != nil {
+		c.OnEvicted(string(r.key), r.value, reason)
+	}
+}
+
+// Remove 删除key对应的记录，返回记录是否存在。
+func (c *Cache) Remove(key string) bool {
+	if r, ok := c.lookup(key); ok {
+		c.removeRecord(r, lru.EvictRemoved)
+		return true
+	}
+	return false
+}
+
+// Evict 以EvictCapacity的原因删除key对应的记录，返回记录是否存在。用于由外部（例如共享的内存池）决定淘汰哪条记录。
+func (c *Cache) Evict(key string) bool {
+	if r, ok := c.lookup(key); ok {
+		c.removeRecord(r, lru.EvictCapacity)
+		return true
+	}
+	return false
+}
+
+// RemoveOldest 淘汰最早写入的一条记录，返回是否淘汰成功。被删除的记录占用的空间同时被回收。
+func (c *Cache) RemoveOldest() bool {
+	for c.tail < c.head {
+		if c.advanceTail() {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveExpired 主动删除过期的记录，每次最多随机抽查samples条记录（利用map遍历顺序的随机性），返回被删除的记录数。
+func (c *Cache) RemoveExpired(samples int) int {
+	now := time.Now().UnixNano()
+	removed := 0
+	for _, off := range c.index {
+		if samples <= 0 {
+			break
+		}
+		samples--
+		if r := c.read(off); r.expired(now) {
+			c.removeRecord(r, lru.EvictExpired)
+			removed++
+		}
+	}
+	return removed
+}
+
+// SampleOldest 随机抽查最多samples条记录，返回其中最久没有被访问的记录的key和访问时间。需要设置Clock。
+func (c *Cache) SampleOldest(samples int) (key string, atime int64, ok bool) {
+	for _, off := range c.index {
+		if samples <= 0 {
+			break
+		}
+		samples--
+		if r := c.read(off); !ok || r.atime < atime {
+			key, atime, ok = string(r.key), r.atime, true
+		}
+	}
+	return
+}
+
+// 按照写入顺序（从旧到新）遍历仍然在索引中的记录，fn返回false时停止遍历。
+func (c *Cache) scan(fn func(r record) bool) {
+	for off := c.tail; off < c.head; {
+		slab, pos := c.locate(off)
+		if rest := c.slabSize - pos; rest < headerSize || binary.LittleEndian.Uint32(slab[pos+4:]) == paddingMark {
+			off += uint64(rest)
+			continue
+		}
+		r := c.read(off)
+		off += uint64(r.size)
+		if cur, ok := c.index[r.hash]; ok && cur == r.off && !fn(r) {
+			return
+		}
+	}
+}
+
+// Keys 返回所有没有过期的key，越晚被淘汰（越晚写入）的key越靠前。
+func (c *Cache) Keys() []string {
+	now := time.Now().UnixNano()
+	keys := make([]string, 0, len(c.index))
+	c.scan(func(r record) bool {
+		if !r.expired(now) {
+			keys = append(keys, string(r.key))
+		}
+		return true
+	})
+	for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+		keys[i], keys[j] = keys[j], keys[i]
+	}
+	return keys
+}
+
+// Range 按照写入顺序遍历所有没有过期的记录，fn返回false时停止遍历。value指向slab中的内存，只在fn执行期间有效。
+// fn中不能修改Cache。
+func (c *Cache) Range(fn func(key string, value []byte) bool) {
+	now := time.Now().UnixNano()
+	c.scan(func(r record) bool {
+		return r.expired(now) || fn(string(r.key), r.value)
+	})
+}
+
+// Resize 修改最大内存容量，返回被淘汰的记录数。所有记录按照写入顺序重新写入新的slab，
+// 缩小容量时最早写入的记录被淘汰，同时回收旧的slab和被删除的记录占用的空间。
+func (c *Cache) Resize(maxBytes int64) int {
+	if maxBytes <= 0 {
+		panic("slab: maxBytes must be positive")
+	}
+	var old []record
+	c.scan(func(r record) bool {
+		old = append(old, r)
+		return true
+	})
+	// old中的key和value仍然指向旧的slab，重新写入之后旧的slab才会被回收。
+	c.reset(maxBytes)
+	evicted := 0
+	onEvicted := c.OnEvicted
+	c.OnEvicted = func(key string, value []byte, reason lru.EvictReason) {
+		evicted++
+		if onEvicted != nil {
+			onEvicted(key, value, reason)
+		}
+	}
+	defer func() { c.OnEvicted = onEvicted }()
+	for _, r := range old {
+		var expire time.Time
+		if r.expire != 0 {
+			expire = time.Unix(0, r.expire)
+		}
+		// 保留原来的访问时间。记录可能因为超过新的容量或者slab大小被淘汰，不能在写入之后再修改。
+		c.add(string(r.key), r.value, expire, r.atime)
+	}
+	return evicted
+}
+
+// Clear 删除所有的记录，每条记录都会以EvictCleared调用回调函数。已经分配的slab会被保留。
+func (c *Cache) Clear() {
+	c.scan(func(r record) bool {
+		if c.OnEvicted != nil {
+			c.OnEvicted(string(r.key), r.value, lru.EvictCleared)
+		}
+		return true
+	})
+	c.head, c.tail = 0, 0
+	c.index = make(map[uint64]uint64)
+}
+
+// Len 返回记录的数量。
+func (c *Cache) Len() int {
+	return len(c.index)
+}
+
+// Bytes 返回环形缓冲区中已经写入的内存，包括被删除或者被更新、还没有被回收的记录。
+func (c *Cache) Bytes() int64 {
+	return int64(c.head - c.tail)
+}
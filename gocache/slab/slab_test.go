@@ -0,0 +1,195 @@
+package slab
+
+import (
+	"GoCache/gocache/lru"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 每条记录占用headerSize + len("kN") + len("vN")字节。
+const recordSize = headerSize + 4
+
+func TestGet(t *testing.T) {
+	c := New(1<<10, 0, nil)
+	c.Add("k1", []byte("Hello World"))
+
+	if v, ok := c.Get("k1"); !ok || string(v) != "Hello World" {
+		t.Fatalf("cache hit {key: k1, value: Hello World} failed")
+	}
+	if _, ok := c.Get("k2"); ok {
+		t.Fatalf("cache miss k2 failed")
+	}
+
+	// 更新后返回新值，旧记录的空间暂时不回收。
+	c.Add("k1", []byte("v1"))
+	if v, ok := c.Get("k1"); !ok || string(v) != "v1" || c.Len() != 1 {
+		t.Fatalf("unexpected value %q after update", v)
+	}
+	if c.Bytes() != headerSize+2+11+recordSize {
+		t.Fatalf("unexpected bytes %d", c.Bytes())
+	}
+}
+
+func TestFIFO(t *testing.T) {
+	var evicted []string
+	c := New(3*recordSize, 0, func(key string, value []byte, reason lru.EvictReason) {
+		evicted = append(evicted, fmt.Sprintf("%s=%s:%s", key, value, reason))
+	})
+	for i := 1; i <= 4; i++ {
+		c.Add(fmt.Sprintf("k%d", i), []byte(fmt.Sprintf("v%d", i)))
+	}
+	if _, ok := c.Get("k1"); ok || c.Len() != 3 {
+		t.Fatalf("k1 should be evicted, len %d", c.Len())
+	}
+	if keys := c.Keys(); !reflect.DeepEqual(keys, []string{"k4", "k3", "k2"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+
+	c.Remove("k2")
+	c.Add("k3", []byte("v5"))
+	// k2已经被删除，k3的旧记录已经被替换，环形缓冲区绕回来时只回收空间，不会再次淘汰。
+	c.Add("k6", []byte("v6"))
+	expect := []string{"k1=v1:capacity", "k2=v2:removed", "k3=v3:replaced"}
+	if !reflect.DeepEqual(evicted, expect) {
+		t.Fatalf("expected %v, got %v", expect, evicted)
+	}
+	if keys := c.Keys(); !reflect.DeepEqual(keys, []string{"k6", "k3", "k4"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+}
+
+func TestSlabBoundary(t *testing.T) {
+	// 每块slab只能放下2条记录，剩余的空间写不下记录头，需要跳到下一块slab。
+	c := New(6*recordSize, 2*recordSize+10, nil)
+	for i := 0; i < 100; i++ {
+		c.Add(fmt.Sprintf("k%d", i%10), []byte(fmt.Sprintf("v%d", i%10)))
+		if c.Bytes() > 6*recordSize {
+			t.Fatalf("bytes %d exceed limit", c.Bytes())
+		}
+		if v, ok := c.Get(fmt.Sprintf("k%d", i%10)); !ok || string(v) != fmt.Sprintf("v%d", i%10) {
+			t.Fatalf("unexpected value %q at %d", v, i)
+		}
+	}
+	if len(c.slabs) > 3 {
+		t.Fatalf("expected at most 3 slabs, got %d", len(c.slabs))
+	}
+
+	// 较大的记录会在slab末尾留下足够写入记录头的填充。
+	c = New(4*recordSize, 2*recordSize+40, nil)
+	for i := 0; i < 50; i++ {
+		c.Add(fmt.Sprintf("k%d", i%5), []byte(fmt.Sprintf("v%d", i%5)))
+		c.Add("big", make([]byte, recordSize))
+		if _, ok := c.Get("big"); !ok {
+			t.Fatalf("big should be cached at %d", i)
+		}
+	}
+
+	var reasons []lru.EvictReason
+	c.OnEvicted = func(key string, value []byte, reason lru.EvictReason) {
+		reasons = append(reasons, reason)
+	}
+	c.Add("huge", make([]byte, 3*recordSize))
+	if c.Contains("huge") || !reflect.DeepEqual(reasons, []lru.EvictReason{lru.EvictCapacity}) {
+		t.Fatalf("record larger than a slab should be rejected, got %v", reasons)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	c := New(1<<10, 0, nil)
+	c.AddWithExpire("k1", []byte("v1"), time.Now().Add(-time.Second))
+	c.AddWithExpire("k2", []byte("v2"), time.Now().Add(time.Hour))
+	if _, ok := c.Peek("k1"); ok || c.Len() != 2 {
+		t.Fatal("expired k1 should be invisible to Peek but not removed")
+	}
+	if n := c.RemoveExpired(10); n != 1 || c.Len() != 1 {
+		t.Fatalf("expected 1 expired record, got %d", n)
+	}
+	if v, ok := c.Get("k2"); !ok || string(v) != "v2" {
+		t.Fatalf("unexpected value %q", v)
+	}
+}
+
+func TestMaxEntries(t *testing.T) {
+	c := New(1<<10, 0, nil)
+	c.MaxEntries = 2
+	for i := 1; i <= 3; i++ {
+		c.Add(fmt.Sprintf("k%d", i), []byte("v"))
+	}
+	if keys := c.Keys(); !reflect.DeepEqual(keys, []string{"k3", "k2"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+}
+
+func TestSampleOldest(t *testing.T) {
+	now := int64(0)
+	c := New(1<<10, 0, nil)
+	c.Clock = func() int64 { now++; return now }
+	c.Add("k1", []byte("v1"))
+	c.Add("k2", []byte("v2"))
+	c.Get("k1")
+	if key, atime, ok := c.SampleOldest(5); !ok || key != "k2" || atime != 2 {
+		t.Fatalf("unexpected oldest %s %d %v", key, atime, ok)
+	}
+	if !c.Evict("k2") || c.Contains("k2") {
+		t.Fatal("k2 should be evicted")
+	}
+}
+
+func TestResizeClear(t *testing.T) {
+	var evicted []string
+	c := New(4*recordSize, 0, func(key string, value []byte, reason lru.EvictReason) {
+		evicted = append(evicted, key+":"+reason.String())
+	})
+	for i := 1; i <= 4; i++ {
+		c.Add(fmt.Sprintf("k%d", i), []byte(fmt.Sprintf("v%d", i)))
+	}
+	c.Remove("k3")
+	evicted = nil
+
+	if n := c.Resize(2 * recordSize); n != 1 || c.Bytes() != 2*recordSize {
+		t.Fatalf("expected 1 eviction, got %d, bytes %d", n, c.Bytes())
+	}
+	if keys := c.Keys(); !reflect.DeepEqual(keys, []string{"k4", "k2"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+	c.Resize(8 * recordSize)
+	c.Add("k5", []byte("v5"))
+	if c.Len() != 3 {
+		t.Fatalf("expected 3 records, got %d", c.Len())
+	}
+
+	c.Clear()
+	expect := []string{"k1:capacity", "k2:cleared", "k4:cleared", "k5:cleared"}
+	if !reflect.DeepEqual(evicted, expect) || c.Len() != 0 || c.Bytes() != 0 {
+		t.Fatalf("expected %v, got %v", expect, evicted)
+	}
+	var n int
+	c.Range(func(key string, value []byte) bool { n++; return true })
+	if n != 0 {
+		t.Fatalf("expected empty cache, got %d records", n)
+	}
+}
+
+func TestResizeClock(t *testing.T) {
+	now := int64(0)
+	c := New(4*recordSize, 0, nil)
+	c.Clock = func() int64 { now++; return now }
+	for i := 1; i <= 4; i++ {
+		c.Add(fmt.Sprintf("k%d", i), []byte(fmt.Sprintf("v%d", i)))
+	}
+
+	// 缩小容量时淘汰的记录不会修改其他记录的访问时间。
+	if n := c.Resize(2 * recordSize); n != 2 {
+		t.Fatalf("expected 2 evictions, got %d", n)
+	}
+	if key, atime, ok := c.SampleOldest(5); !ok || key != "k3" || atime != 3 {
+		t.Fatalf("unexpected oldest %s %d %v", key, atime, ok)
+	}
+
+	// 新的slab放不下任何记录时，所有记录都被丢弃，不能panic。
+	if n := c.Resize(recordSize - 1); n != 2 || c.Len() != 0 {
+		t.Fatalf("expected all records to be dropped, got %d evictions, len %d", n, c.Len())
+	}
+}
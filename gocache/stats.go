@@ -0,0 +1,71 @@
+package gocache
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// AtomicInt 可以被并发地原子更新的int64计数器。
+type AtomicInt int64
+
+// Add 原子地将n加到i上。
+func (i *AtomicInt) Add(n int64) {
+	atomic.AddInt64((*int64)(i), n)
+}
+
+// Get 原子地读取i的值。
+func (i *AtomicInt) Get() int64 {
+	return atomic.LoadInt64((*int64)(i))
+}
+
+func (i *AtomicInt) String() string {
+	return strconv.FormatInt(i.Get(), 10)
+}
+
+// Stats Group的统计数据。
+type Stats struct {
+	Gets          AtomicInt // 调用Get的次数（包括远程节点发来的请求）。
+	CacheHits     AtomicInt // mainCache或hotCache命中的次数。
+	Loads         AtomicInt // 需要加载的次数：未命中缓存且没有被负缓存或布隆过滤器拦截的key，加上后台刷新，不等于Gets - CacheHits。
+	LoadsDeduped  AtomicInt // 经过singleflight去重后，真正执行加载的次数。
+	PeerLoads     AtomicInt // 从远程节点成功获取的次数。
+	PeerErrors    AtomicInt // 从远程节点获取失败的次数。
+	LocalLoads    AtomicInt // 调用回调函数成功加载的次数。
+	LocalLoadErrs AtomicInt // 调用回调函数加载失败的次数。
+	NegativeHits  AtomicInt // 命中负缓存（数据不存在）的次数。
+	BloomRejects  AtomicInt // 被布隆过滤器拒绝（key一定不存在）的次数。
+	StaleHits     AtomicInt // 返回软过期的旧值的次数。
+	Refreshes     AtomicInt // 启动后台刷新的次数。
+	// CapacityController因为内存压力缩小和恢复缓存容量的次数。
+	CapacityShrinks AtomicInt
+	CapacityGrows   AtomicInt
+
+	// 以下字段只在Group.Stats()返回的快照中有值。
+	Evictions AtomicInt  // mainCache和hotCache中被淘汰（包括过期）的缓存项数量。
+	MainCache CacheStats // mainCache的统计数据，包括内存和缓存项数量。
+	HotCache  CacheStats // hotCache的统计数据，包括内存和缓存项数量。
+}
+
+// Stats 返回Group统计数据的快照。
+func (g *Group) Stats() Stats {
+	s := Stats{
+		Gets:            AtomicInt(g.stats.Gets.Get()),
+		CacheHits:       AtomicInt(g.stats.CacheHits.Get()),
+		Loads:           AtomicInt(g.stats.Loads.Get()),
+		LoadsDeduped:    AtomicInt(g.stats.LoadsDeduped.Get()),
+		PeerLoads:       AtomicInt(g.stats.PeerLoads.Get()),
+		PeerErrors:      AtomicInt(g.stats.PeerErrors.Get()),
+		LocalLoads:      AtomicInt(g.stats.LocalLoads.Get()),
+		LocalLoadErrs:   AtomicInt(g.stats.LocalLoadErrs.Get()),
+		NegativeHits:    AtomicInt(g.stats.NegativeHits.Get()),
+		BloomRejects:    AtomicInt(g.stats.BloomRejects.Get()),
+		StaleHits:       AtomicInt(g.stats.StaleHits.Get()),
+		Refreshes:       AtomicInt(g.stats.Refreshes.Get()),
+		CapacityShrinks: AtomicInt(g.stats.CapacityShrinks.Get()),
+		CapacityGrows:   AtomicInt(g.stats.CapacityGrows.Get()),
+		MainCache:       g.mainCache.stats(),
+		HotCache:        g.hotCache.stats(),
+	}
+	s.Evictions = AtomicInt(s.MainCache.Evictions + s.HotCache.Evictions)
+	return s
+}
@@ -0,0 +1,153 @@
+package gocache
+
+import (
+	"GoCache/gocache/lru"
+	"GoCache/gocache/slab"
+	"encoding/binary"
+	"time"
+)
+
+// store cacheShard使用的存储，默认是lru.TypedCache，开启WithSlabStorage时是slabStore。
+type store interface {
+	Get(key string) (ByteView, bool)
+	Peek(key string) (ByteView, bool)
+	AddWithExpire(key string, value ByteView, expire time.Time)
+	Remove(key string) bool
+	Evict(key string) bool
+	RemoveOldest()
+	RemoveExpired(samples int) int
+	SampleOldest(samples int) (key string, atime int64, ok bool)
+	Keys() []string
+	Range(fn func(key string, value ByteView) bool)
+	Resize(maxBytes int64) int
+	Clear()
+	Len() int
+	Bytes() int64
+}
+
+var _ store = (*lru.TypedCache[string, ByteView])(nil)
+
+// ByteView中stale和expire的编码长度，写在slab中每个值的前面。
+const slabViewHeader = 16
+
+// slabStore 将ByteView编码后保存在slab.Cache中，GC不需要扫描每条缓存项。
+// 缓存值在Get时被复制出来；被淘汰的值同样复制一份之后再交给回调函数。
+type slabStore struct {
+	c *slab.Cache
+}
+
+func newSlabStore(maxBytes, slabSize int64, onEvicted evictFunc) *slabStore {
+	s := &slabStore{c: slab.New(maxBytes, slabSize, nil)}
+	if onEvicted != nil {
+		s.c.OnEvicted = func(key string, value []byte, reason lru.EvictReason) {
+			onEvicted(key, decodeView(value, true), reason)
+		}
+	}
+	return s
+}
+
+// 编码为stale(8) + expire(8) + 缓存值，零值时间编码为0。
+func encodeView(v ByteView) []byte {
+	b := make([]byte, slabViewHeader+len(v.b))
+	binary.LittleEndian.PutUint64(b, uint64(unixNano(v.stale)))
+	binary.LittleEndian.PutUint64(b[8:], uint64(unixNano(v.expire)))
+	copy(b[slabViewHeader:], v.b)
+	return b
+}
+
+// clone为true时复制缓存值，用于b指向slab中内存的情况。
+func decodeView(b []byte, clone bool) ByteView {
+	v := ByteView{
+		b:      b[slabViewHeader:],
+		stale:  fromUnixNano(int64(binary.LittleEndian.Uint64(b))),
+		expire: fromUnixNano(int64(binary.LittleEndian.Uint64(b[8:]))),
+	}
+	if clone {
+		v.b = cloneBytes(v.b)
+	}
+	return v
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+func (s *slabStore) Get(key string) (ByteView, bool) {
+	b, ok := s.c.Get(key)
+	if !ok {
+		return ByteView{}, false
+	}
+	return decodeView(b, false), true
+}
+
+func (s *slabStore) Peek(key string) (ByteView, bool) {
+	b, ok := s.c.Peek(key)
+	if !ok {
+		return ByteView{}, false
+	}
+	return decodeView(b, false), true
+}
+
+func (s *slabStore) AddWithExpire(key string, value ByteView, expire time.Time) {
+	s.c.AddWithExpire(key, encodeView(value), expire)
+}
+
+func (s *slabStore) Remove(key string) bool {
+	return s.c.Remove(key)
+}
+
+func (s *slabStore) Evict(key string) bool {
+	return s.c.Evict(key)
+}
+
+func (s *slabStore) RemoveOldest() {
+	s.c.RemoveOldest()
+}
+
+func (s *slabStore) RemoveExpired(samples int) int {
+	return s.c.RemoveExpired(samples)
+}
+
+func (s *slabStore) SampleOldest(samples int) (string, int64, bool) {
+	return s.c.SampleOldest(samples)
+}
+
+func (s *slabStore) Keys() []string {
+	return s.c.Keys()
+}
+
+func (s *slabStore) Range(fn func(key string, value ByteView) bool) {
+	s.c.Range(func(key string, value []byte) bool {
+		return fn(key, decodeView(value, true))
+	})
+}
+
+// Resize slab.Cache的容量是固定的环形缓冲区，无法表示lru.Cache中maxBytes <= 0代表的不限制，此时保持当前容量。
+func (s *slabStore) Resize(maxBytes int64) int {
+	if maxBytes <= 0 {
+		return 0
+	}
+	return s.c.Resize(maxBytes)
+}
+
+func (s *slabStore) Clear() {
+	s.c.Clear()
+}
+
+func (s *slabStore) Len() int {
+	return s.c.Len()
+}
+
+func (s *slabStore) Bytes() int64 {
+	return s.c.Bytes()
+}
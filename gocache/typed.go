@@ -0,0 +1,152 @@
+package gocache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec 负责在类型T和缓存中保存的[]byte之间转换。Unmarshal收到的data是缓存值的拷贝，可以保留或者修改。
+type Codec[T any] interface {
+	Marshal(v T) ([]byte, error)
+	Unmarshal(data []byte) (T, error)
+}
+
+// JSONCodec 使用encoding/json编码。
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Marshal(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// GobCodec 使用encoding/gob编码。
+type GobCodec[T any] struct{}
+
+func (GobCodec[T]) Marshal(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return v, err
+}
+
+// ProtoCodec 使用protobuf编码，T是生成的消息的指针类型，例如ProtoCodec[*pb.Request]。
+type ProtoCodec[T proto.Message] struct{}
+
+func (ProtoCodec[T]) Marshal(v T) ([]byte, error) {
+	return proto.Marshal(v)
+}
+
+func (ProtoCodec[T]) Unmarshal(data []byte) (T, error) {
+	// 生成的消息类型的nil指针也可以调用ProtoReflect()，用来创建新的消息。
+	var zero T
+	v := zero.ProtoReflect().Type().New().Interface().(T)
+	err := proto.Unmarshal(data, v)
+	return v, err
+}
+
+// StringCodec 不做任何编码，直接保存字符串的内容。
+type StringCodec struct{}
+
+func (StringCodec) Marshal(v string) ([]byte, error) {
+	return []byte(v), nil
+}
+
+func (StringCodec) Unmarshal(data []byte) (string, error) {
+	return string(data), nil
+}
+
+// TypedGetter 缓存未命中时获取类型为T的源数据的回调，与Getter类似。
+type TypedGetter[T any] interface {
+	Get(ctx context.Context, key string) (T, error)
+}
+
+// TypedGetterFunc 接口型函数，实现了TypedGetter接口。
+type TypedGetterFunc[T any] func(ctx context.Context, key string) (T, error)
+
+// Get TypedGetterFunc类型的成员方法。
+func (f TypedGetterFunc[T]) Get(ctx context.Context, key string) (T, error) {
+	return f(ctx, key)
+}
+
+// TypedGroup 在Group的基础上，使用Codec自动完成缓存值的编码和解码，Get直接返回类型为T的值。
+// 缓存中保存的仍然是编码后的[]byte，所以每次Get都会解码一次，并且远程节点需要使用相同的Codec。
+type TypedGroup[T any] struct {
+	group *Group
+	codec Codec[T]
+}
+
+// NewTypedGroup 实例化TypedGroup，底层的Group同样通过GetGroup注册，opts与NewGroup相同。
+func NewTypedGroup[T any](name string, cacheBytes int64, codec Codec[T], getter TypedGetter[T], opts ...GroupOption) *TypedGroup[T] {
+	if getter == nil {
+		panic("nil Getter")
+	}
+	g := NewGroup(name, cacheBytes, ContextGetterFunc(func(ctx context.Context, key string) ([]byte, error) {
+		v, err := getter.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		return codec.Marshal(v)
+	}), opts...)
+	return &TypedGroup[T]{group: g, codec: codec}
+}
+
+// Group 返回底层的Group，例如用于RegisterPeers和Stats。
+func (t *TypedGroup[T]) Group() *Group {
+	return t.group
+}
+
+// Get 返回key对应的值。
+func (t *TypedGroup[T]) Get(ctx context.Context, key string) (T, error) {
+	view, err := t.group.Get(ctx, key)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return t.codec.Unmarshal(view.ByteSlice())
+}
+
+// GetMulti 批量获取多个key的值，语义与Group.GetMulti相同。
+func (t *TypedGroup[T]) GetMulti(ctx context.Context, keys []string) (map[string]T, error) {
+	views, err := t.group.GetMulti(ctx, keys)
+	values := make(map[string]T, len(views))
+	for key, view := range views {
+		v, decodeErr := t.codec.Unmarshal(view.ByteSlice())
+		if decodeErr != nil {
+			if err == nil {
+				err = decodeErr
+			}
+			continue
+		}
+		values[key] = v
+	}
+	return values, err
+}
+
+// Set 写入key对应的值，语义与Group.Set相同。
+func (t *TypedGroup[T]) Set(ctx context.Context, key string, v T) error {
+	data, err := t.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return t.group.Set(ctx, key, data)
+}
+
+// Remove 删除key对应的值，语义与Group.Remove相同。
+func (t *TypedGroup[T]) Remove(ctx context.Context, key string) error {
+	return t.group.Remove(ctx, key)
+}
@@ -0,0 +1,104 @@
+package gocache
+
+import (
+	pb "GoCache/gocachepb"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type student struct {
+	Name  string
+	Score int
+}
+
+func TestCodecs(t *testing.T) {
+	s := student{Name: "Tom", Score: 630}
+	for name, codec := range map[string]Codec[student]{"json": JSONCodec[student]{}, "gob": GobCodec[student]{}} {
+		data, err := codec.Marshal(s)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if v, err := codec.Unmarshal(data); err != nil || v != s {
+			t.Fatalf("%s: expected %v, got %v, %v", name, s, v, err)
+		}
+	}
+
+	req := &pb.Request{Group: "scores", Key: "Tom"}
+	data, err := ProtoCodec[*pb.Request]{}.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := (ProtoCodec[*pb.Request]{}).Unmarshal(data); err != nil || v.GetGroup() != "scores" || v.GetKey() != "Tom" {
+		t.Fatalf("unexpected proto message %v, %v", v, err)
+	}
+}
+
+func TestTypedGroup(t *testing.T) {
+	loads := 0
+	group := NewTypedGroup[student]("typed", 2<<10, JSONCodec[student]{}, TypedGetterFunc[student](
+		func(ctx context.Context, key string) (student, error) {
+			loads++
+			if key == "unknown" {
+				return student{}, fmt.Errorf("%s not exist: %w", key, ErrNotFound)
+			}
+			return student{Name: key, Score: 630}, nil
+		}))
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if v, err := group.Get(ctx, "Tom"); err != nil || v != (student{"Tom", 630}) {
+			t.Fatalf("unexpected value %v, %v", v, err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("expected 1 load, got %d", loads)
+	}
+	if _, err := group.Get(ctx, "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := group.Set(ctx, "Jack", student{"Jack", 589}); err != nil {
+		t.Fatal(err)
+	}
+	values, err := group.GetMulti(ctx, []string{"Tom", "Jack"})
+	if err != nil || !reflect.DeepEqual(values, map[string]student{"Tom": {"Tom", 630}, "Jack": {"Jack", 589}}) {
+		t.Fatalf("unexpected values %v, %v", values, err)
+	}
+	if GetGroup("typed") != group.Group() {
+		t.Fatal("typed group should be registered")
+	}
+}
+
+// Unmarshal时修改data的Codec。
+type scribbleCodec struct{}
+
+func (scribbleCodec) Marshal(v string) ([]byte, error) { return []byte(v), nil }
+
+func (scribbleCodec) Unmarshal(data []byte) (string, error) {
+	v := string(data)
+	for i := range data {
+		data[i] = 'x'
+	}
+	return v, nil
+}
+
+func TestTypedGroupCodecCopy(t *testing.T) {
+	group := NewTypedGroup[string]("typed-copy", 2<<10, scribbleCodec{}, TypedGetterFunc[string](
+		func(ctx context.Context, key string) (string, error) {
+			return "630", nil
+		}))
+
+	// Codec修改data不影响缓存中的值。
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if v, err := group.Get(ctx, "Tom"); err != nil || v != "630" {
+			t.Fatalf("unexpected value %q, %v", v, err)
+		}
+		if values, err := group.GetMulti(ctx, []string{"Tom"}); err != nil || values["Tom"] != "630" {
+			t.Fatalf("unexpected values %v, %v", values, err)
+		}
+	}
+}
@@ -2,10 +2,13 @@ package main
 
 import (
 	"GoCache/gocache"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 )
 
 var db = map[string]string{
@@ -16,15 +19,28 @@ var db = map[string]string{
 
 // 实例化一个Group。
 func createGroup() *gocache.Group {
-	return gocache.NewGroup("scores", 2<<10, gocache.GetterFunc(
+	g := gocache.NewGroup("scores", 2<<10, gocache.GetterFunc(
 		// 实例化的Group的名称为scores，大小为1024字节，回调函数为调用本地DB查询数据。
 		func(key string) ([]byte, error) {
 			log.Println("[SlowDB] search key", key)
 			if v, ok := db[key]; ok {
 				return []byte(v), nil
 			}
-			return nil, fmt.Errorf("%s not exist", key)
+			// 包装ErrNotFound，数据不存在的结果会被负缓存10秒，避免缓存穿透。
+			return nil, fmt.Errorf("%s not exist: %w", key, gocache.ErrNotFound)
+		}), gocache.WithNegativeTTL(10*time.Second),
+		// 使用布隆过滤器拦截数据库中一定不存在的key。
+		gocache.WithBloomFilter(len(db), 0.01, func(ctx context.Context) ([]string, error) {
+			keys := make([]string, 0, len(db))
+			for k := range db {
+				keys = append(keys, k)
+			}
+			return keys, nil
 		}))
+	if err := g.LoadBloomFilter(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	return g
 }
 
 // 用来启动一个API服务（端口9999），与用户进行交互，用户感知。
@@ -38,7 +54,11 @@ func startAPIServer(apiAddr string, goc *gocache.Group) {
 			// 获取请求参数，key。
 			key := r.URL.Query().Get("key")
 			// 调用主服务Group获取缓存。
-			view, err := goc.Get(key)
+			view, err := goc.Get(r.Context(), key)
+			if errors.Is(err, gocache.ErrNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
@@ -53,12 +73,21 @@ func startAPIServer(apiAddr string, goc *gocache.Group) {
 }
 
 // 启动缓存服务器：创建HTTPPool，添加节点信息，注册到gocache中，启动HTTP服务（共3个端口，8001/8002/8003），用户不感知。
+// curl "http://localhost:8001/metrics" 可以查看该节点的Prometheus指标。
+// curl "http://localhost:8001/admin" 可以查看该节点认为在线的其他节点，以及统计数据。
 func startCacheServer(addr string, addrs []string, goc *gocache.Group) {
 	peers := gocache.NewHTTPPool(addr)
 	peers.Set(addrs...)
+	// 定期探测其他节点的/_gocache/health，下线的节点不参与路由。
+	peers.StartHealthCheck(gocache.HealthConfig{})
 	goc.RegisterPeers(peers)
+	// 节点间通讯、Prometheus指标和管理接口使用同一个端口。
+	mux := http.NewServeMux()
+	mux.Handle("/_gocache/", peers)
+	mux.Handle("/metrics", gocache.MetricsHandler())
+	mux.Handle("/admin", peers.AdminHandler())
 	log.Println("gocache is running at", addr)
-	log.Fatal(http.ListenAndServe(addr[7:], peers))
+	log.Fatal(http.ListenAndServe(addr[7:], mux))
 }
 
 func main() {